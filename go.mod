@@ -1,6 +1,7 @@
 module github.com/saibing/bingo
 
 require (
+	github.com/BurntSushi/toml v0.3.0
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fsnotify/fsevents v0.1.1
 	github.com/fsnotify/fsnotify v1.4.7
@@ -42,6 +42,17 @@ func goRangeToLSPLocation(fSet *token.FileSet, pos token.Pos, name string) lsp.L
 	}
 }
 
+// positionToLSPLocation converts the position of name into a lsp.Location.
+func positionToLSPLocation(position token.Position, name string) lsp.Location {
+	start := lsp.Position{Line: position.Line - 1, Character: position.Column - 1}
+	end := start
+	end.Character += len(name)
+	return lsp.Location{
+		URI:   lsp.DocumentURI(source.ToURI(position.Filename)),
+		Range: lsp.Range{Start: start, End: end},
+	}
+}
+
 func createLocationFromRange(fSet *token.FileSet, pos token.Pos, end token.Pos) lsp.Location {
 	return lsp.Location{
 		URI:   lsp.DocumentURI(source.ToURI(fSet.Position(pos).Filename)),
@@ -0,0 +1,197 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/sourcegraph/go-lsp"
+)
+
+// BuildConfiguration is a GOOS/GOARCH/build tags combination under which the
+// packages are type-checked in addition to the host configuration.
+type BuildConfiguration = cache.BuildConfiguration
+
+// ParseBuildConfigurations parses a comma separated list of configurations
+// such as "linux/amd64,windows/amd64+integration".
+func ParseBuildConfigurations(s string) ([]BuildConfiguration, error) {
+	return cache.ParseBuildConfigurations(s)
+}
+
+// configurationDiagnostics computes the diagnostics of pkg under the host
+// configuration, and its parse and type errors under each of the build
+// configurations of config. An error which doesn't occur under every
+// configuration has the list of the configurations it occurs in appended to
+// its message. Analyzers and deprecation checks only run on the host.
+//
+// Loading a package under every configuration is costly, so the errors of
+// the configurations are taken from saved, when not nil, until a file of the
+// package is saved again.
+func configurationDiagnostics(ctx context.Context, project *cache.Project, pkg source.Package, config *Config, saved *configurationReports) map[string][]protocol.Diagnostic {
+	reports := packageDiagnostics(ctx, pkg, config)
+	if config == nil || len(config.BuildConfigurations) == 0 || len(pkg.GetFilenames()) == 0 {
+		return reports
+	}
+
+	host := project.HostConfiguration()
+	dir := filepath.Dir(pkg.GetFilenames()[0])
+	matrix := newDiagnosticsMatrix()
+	matrix.add(host.String(), reports)
+	cached, gen := saved.get(dir)
+	computed := make(map[string]map[string][]protocol.Diagnostic)
+	for _, c := range config.BuildConfigurations {
+		if c.String() == host.String() || matrix.has(c.String()) {
+			continue
+		}
+
+		cReports, ok := cached[c.String()]
+		if !ok {
+			pkgs, err := project.LoadWith(ctx, dir, c)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("failed to load %s for %s: %v", dir, c, err)
+				}
+				continue
+			}
+			cReports = map[string][]protocol.Diagnostic{}
+			for _, p := range pkgs {
+				errors, _ := compilerDiagnostics(p)
+				mergeReports(cReports, errors)
+			}
+		}
+		computed[c.String()] = cReports
+		matrix.add(c.String(), cReports)
+	}
+	if ctx.Err() == nil {
+		saved.set(dir, gen, computed)
+	}
+	return matrix.reports()
+}
+
+// configurationReports are the parse and type errors of the packages under
+// the build configurations, by package directory and configuration. They
+// are computed again once a file of the package is saved.
+type configurationReports struct {
+	mu      sync.Mutex
+	reports map[string]map[string]map[string][]protocol.Diagnostic
+	// gens counts the saves of the files of the packages, so that the
+	// errors computed before a save are not kept.
+	gens map[string]int
+}
+
+func newConfigurationReports() *configurationReports {
+	return &configurationReports{
+		reports: make(map[string]map[string]map[string][]protocol.Diagnostic),
+		gens:    make(map[string]int),
+	}
+}
+
+// get returns the errors of the package of dir by configuration, and the
+// generation to pass to set.
+func (r *configurationReports) get(dir string) (map[string]map[string][]protocol.Diagnostic, int) {
+	if r == nil {
+		return nil, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reports[dir], r.gens[dir]
+}
+
+// set replaces the errors of the package of dir, unless one of its files was
+// saved since get returned gen.
+func (r *configurationReports) set(dir string, gen int, reports map[string]map[string][]protocol.Diagnostic) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gens[dir] == gen {
+		r.reports[dir] = reports
+	}
+}
+
+// saved drops the errors of the package of dir, after one of its files was
+// saved or closed.
+func (r *configurationReports) saved(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gens[dir]++
+	delete(r.reports, dir)
+}
+
+type diagnosticKey struct {
+	filename string
+	rng      lsp.Range
+	source   string
+	message  string
+}
+
+// diagnosticsMatrix merges the diagnostics computed under several
+// configurations.
+type diagnosticsMatrix struct {
+	configurations []string
+	files          map[string][]diagnosticKey
+	diagnostics    map[diagnosticKey]protocol.Diagnostic
+	occurrences    map[diagnosticKey][]string
+}
+
+func newDiagnosticsMatrix() *diagnosticsMatrix {
+	return &diagnosticsMatrix{
+		files:       make(map[string][]diagnosticKey),
+		diagnostics: make(map[diagnosticKey]protocol.Diagnostic),
+		occurrences: make(map[diagnosticKey][]string),
+	}
+}
+
+func (m *diagnosticsMatrix) has(configuration string) bool {
+	for _, c := range m.configurations {
+		if c == configuration {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *diagnosticsMatrix) add(configuration string, reports map[string][]protocol.Diagnostic) {
+	m.configurations = append(m.configurations, configuration)
+	for filename, diagnostics := range reports {
+		if _, ok := m.files[filename]; !ok {
+			m.files[filename] = []diagnosticKey{}
+		}
+		for _, d := range diagnostics {
+			key := diagnosticKey{filename: filename, rng: d.Range, source: d.Source, message: d.Message}
+			occurrences, ok := m.occurrences[key]
+			if !ok {
+				m.files[filename] = append(m.files[filename], key)
+				m.diagnostics[key] = d
+			}
+			if len(occurrences) == 0 || occurrences[len(occurrences)-1] != configuration {
+				m.occurrences[key] = append(occurrences, configuration)
+			}
+		}
+	}
+}
+
+func (m *diagnosticsMatrix) reports() map[string][]protocol.Diagnostic {
+	reports := make(map[string][]protocol.Diagnostic, len(m.files))
+	for filename, keys := range m.files {
+		diagnostics := []protocol.Diagnostic{}
+		for _, key := range keys {
+			d := m.diagnostics[key]
+			if occurrences := m.occurrences[key]; d.Source == compilerSource && len(occurrences) < len(m.configurations) {
+				d.Message = fmt.Sprintf("%s [%s]", d.Message, strings.Join(occurrences, ", "))
+			}
+			diagnostics = append(diagnostics, d)
+		}
+		reports[filename] = diagnostics
+	}
+	return reports
+}
@@ -0,0 +1,70 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBuildConfigurations(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	configurations, err := ParseBuildConfigurations("linux/amd64, windows/386+integration+cgo,")
+	require.NoError(err)
+	require.Equal([]BuildConfiguration{
+		{GOOS: "linux", GOARCH: "amd64", Tags: []string{}},
+		{GOOS: "windows", GOARCH: "386", Tags: []string{"integration", "cgo"}},
+	}, configurations)
+	require.Equal("windows/386+integration+cgo", configurations[1].String())
+
+	_, err = ParseBuildConfigurations("linux")
+	require.Error(err)
+}
+
+func TestDiagnosticsMatrix(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	diagnostic := func(line int, message string) protocol.Diagnostic {
+		pos := lsp.Position{Line: line}
+		return protocol.Diagnostic{Diagnostic: lsp.Diagnostic{Range: lsp.Range{Start: pos, End: pos}, Severity: lsp.Error, Source: compilerSource, Message: message}}
+	}
+
+	m := newDiagnosticsMatrix()
+	m.add("linux/amd64", map[string][]protocol.Diagnostic{
+		"/a/a.go":       {diagnostic(1, "everywhere"), diagnostic(2, "linux only")},
+		"/a/a_linux.go": {},
+	})
+	m.add("windows/amd64", map[string][]protocol.Diagnostic{
+		"/a/a.go":         {diagnostic(1, "everywhere")},
+		"/a/a_windows.go": {diagnostic(3, "windows only")},
+	})
+
+	reports := m.reports()
+	require.Equal([]protocol.Diagnostic{diagnostic(1, "everywhere"), diagnostic(2, "linux only [linux/amd64]")}, reports["/a/a.go"])
+	require.Equal([]protocol.Diagnostic{}, reports["/a/a_linux.go"])
+	require.Equal([]protocol.Diagnostic{diagnostic(3, "windows only [windows/amd64]")}, reports["/a/a_windows.go"])
+}
+
+func TestConfigurationReports(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	reports := map[string]map[string][]protocol.Diagnostic{"windows/amd64": {"/a/a.go": {}}}
+	r := newConfigurationReports()
+	cached, gen := r.get("/a")
+	require.Nil(cached)
+	r.set("/a", gen, reports)
+	cached, _ = r.get("/a")
+	require.Equal(reports, cached)
+
+	// The reports computed before a save are dropped.
+	_, gen = r.get("/a")
+	r.saved("/a")
+	r.set("/a", gen, reports)
+	cached, _ = r.get("/a")
+	require.Nil(cached)
+}
@@ -19,11 +19,11 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 		return nil, err
 	}
 
-	if !h.project.Contain(fileURI) {
+	if !h.projectFor(fileURI).Contain(fileURI) {
 		return []protocol.CodeAction{}, nil
 	}
 
-	edits, err := organizeImports(ctx, h.View(), fileURI)
+	edits, err := organizeImports(ctx, h.viewFor(fileURI), fileURI, h.configFor(fileURI).GoimportsLocalPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +40,7 @@ func (h *LangHandler) handleCodeAction(ctx context.Context, conn jsonrpc2.JSONRP
 	}, nil
 }
 
-func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([]lsp.TextEdit, error) {
+func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI, localPrefix string) ([]lsp.TextEdit, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
 		return nil, err
@@ -58,7 +58,7 @@ func organizeImports(ctx context.Context, v source.View, uri lsp.DocumentURI) ([
 		Start: tok.Pos(0),
 		End:   tok.Pos(tok.Size()),
 	}
-	edits, err := source.Imports(ctx, f, r)
+	edits, err := source.Imports(ctx, f, r, localPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -11,19 +11,20 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*lsp.CompletionList, error) {
+func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.CompletionParams) (*protocol.CompletionList, error) {
 	fileURI := params.TextDocument.URI
 	if err := checkFileURI(fileURI); err != nil {
 		return nil, nil
 	}
 
-	f, err := h.View().GetFile(ctx, span.FromDocumentURI(fileURI))
+	f, err := h.viewFor(fileURI).GetFile(ctx, span.FromDocumentURI(fileURI))
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +34,7 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 	}
 
 	pos := fromProtocolPosition(tok, params.Position)
-	items, prefix, err := source.Completion(ctx, f, pos, h.project.Cache())
+	items, prefix, err := source.Completion(ctx, f, pos, h.projectFor(fileURI).Cache())
 	if err != nil {
 		return nil, err
 	}
@@ -42,8 +43,8 @@ func (h *LangHandler) handleTextDocumentCompletion(ctx context.Context, conn jso
 		return nil, ctx.Err()
 	}
 
-	useSnippets := h.clientSupportsSnippets() && !h.config.DisableFuncSnippet
-	result := &lsp.CompletionList{
+	useSnippets := h.clientSupportsSnippets() && !h.configFor(fileURI).DisableFuncSnippet
+	result := &protocol.CompletionList{
 		IsIncomplete: false,
 		Items:        toProtocolCompletionItems(items, prefix, params.Position, useSnippets, false),
 	}
@@ -61,7 +62,7 @@ func getLspRange(pos lsp.Position, rangeLen int) lsp.Range {
 	}
 }
 
-func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool) []lsp.CompletionItem {
+func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string, pos lsp.Position, snippetsSupported, signatureHelpEnabled bool) []protocol.CompletionItem {
 	insertTextFormat := lsp.ITFPlainText
 	if snippetsSupported {
 		insertTextFormat = lsp.ITFSnippet
@@ -69,7 +70,7 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 	sort.SliceStable(candidates, func(i, j int) bool {
 		return candidates[i].Score > candidates[j].Score
 	})
-	items := []lsp.CompletionItem{}
+	items := []protocol.CompletionItem{}
 	for i, candidate := range candidates {
 		// Matching against the label.
 		if !strings.HasPrefix(candidate.Label, prefix) {
@@ -106,7 +107,15 @@ func toProtocolCompletionItems(candidates []source.CompletionItem, prefix string
 		//		Command: "editor.action.triggerParameterHints",
 		//	}
 		//}
-		items = append(items, item)
+		if candidate.Deprecated {
+			items = append(items, protocol.CompletionItem{
+				CompletionItem: item,
+				Tags:           []protocol.CompletionItemTag{protocol.DeprecatedCompletion},
+				Deprecated:     true,
+			})
+			continue
+		}
+		items = append(items, protocol.CompletionItem{CompletionItem: item})
 	}
 	return items
 }
@@ -1,5 +1,11 @@
 package langserver
 
+import (
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+)
+
 // Config adjusts the behaviour of go-langserver. Please keep in sync with
 // InitializationOptions in the README.
 type Config struct {
@@ -15,11 +21,81 @@ type Config struct {
 	// Defaults to "always" if not specified
 	GlobalCacheStyle string
 
+	// IndexDirectory is the directory of the persistent package indexes,
+	// which let the global cache skip type-checking the unchanged packages
+	// on startup. "none" disables the indexes.
+	//
+	// Defaults to the bingo directory of the user cache directory
+	IndexDirectory string
+
+	// IncludePatterns restrict the go.mod files and the packages loaded to
+	// the directories matching these globs and below. The globs are matched
+	// against the slash-separated paths relative to the workspace root: a
+	// glob without a slash matches a directory name at any depth, "**"
+	// matches any number of directories.
+	//
+	// Defaults to empty, which includes everything
+	IncludePatterns []string
+
+	// ExcludePatterns skip the directories matching these globs, and below,
+	// when walking the workspace for go.mod files, watching and loading it.
+	//
+	// Defaults to .git, .svn, .hg, .vscode, .idea, node_modules and vendor
+	ExcludePatterns []string
+
+	// MaxDepth is the maximum depth of the directories below the workspace
+	// root walked for go.mod files and watched. The packages below it are
+	// loaded all the same. 0 disables the limit.
+	//
+	// Defaults to 8
+	MaxDepth int
+
+	// UseIgnoreFiles skips the directories ignored by the .gitignore and
+	// .ignore files of the workspace.
+	//
+	// Defaults to false
+	UseIgnoreFiles bool
+
+	// DependencyMode selects how the dependencies outside of the workspace
+	// are loaded into the global cache: "source" type-checks them from
+	// source, "export" reads their types from their compiled export data and
+	// only loads their source when a request drills into them.
+	//
+	// Defaults to "source"
+	DependencyMode string
+
+	// MemoryLimit is the heap size, in megabytes, above which the syntax and
+	// type information of the least recently used packages of the global
+	// cache are evicted from memory. They are loaded again when a request
+	// needs them. 0 disables the limit.
+	//
+	// Defaults to 0
+	MemoryLimit int
+
+	// WatchMode selects how the files of the workspace are watched when the
+	// client doesn't watch them: "fsnotify", "poll", or "auto" which polls
+	// once the file system watches are exhausted.
+	//
+	// Defaults to "auto"
+	WatchMode string
+
+	// PollInterval is how often the files are polled for changes in "poll"
+	// watch mode.
+	//
+	// Defaults to 2 seconds
+	PollInterval time.Duration
+
 	// DiagnosticsEnabled enables handling of diagnostics
 	//
 	// Defaults to false if not specified.
 	DiagnosticsStyle string
 
+	// DiagnosticsDelay is how long a package has to stay unchanged before its
+	// diagnostics are computed again.
+	//
+	// Defaults to 200 milliseconds
+	DiagnosticsDelay time.Duration
+
 	// FormatStyle format style
 	//
 	// Defaults to "gofmt" if not secified
@@ -39,6 +115,64 @@ type Config struct {
 	//
 	// Defaults to empty
 	BuildTags []string
+
+	// BuildConfigurations are the GOOS/GOARCH/build tags combinations under
+	// which packages are type-checked for diagnostics, in addition to the
+	// host configuration. Their tags are added to the workspace ones. A
+	// package is type-checked under them again when one of its files is
+	// saved.
+	//
+	// Defaults to empty
+	BuildConfigurations []BuildConfiguration
+
+	// ReverseDependencyDepth is how many levels of importers of an edited
+	// package are type-checked again to publish their diagnostics. Zero
+	// disables reverse-dependency diagnostics.
+	//
+	// Defaults to 1
+	ReverseDependencyDepth int
+
+	// ReverseDependencyBudget bounds the total time spent on type-checking
+	// the importers of an edited package in one pass.
+	//
+	// Defaults to 5 seconds
+	ReverseDependencyBudget time.Duration
+
+	// DeprecatedSeverity is the severity of the diagnostics reported on uses
+	// of deprecated symbols: "hint", "info", "warning" or "error". "none"
+	// disables them.
+	//
+	// Defaults to "hint"
+	DeprecatedSeverity string
+
+	// Analyses are the names of the analyzers of the vet suite run on the
+	// packages of the workspace, eg. "printf" or "copylocks", for the
+	// diagnostics of the editor and of the workspace checks. "all" runs the
+	// whole suite.
+	//
+	// Defaults to none
+	Analyses []string
+
+	// Env are the environment variables set for the go commands run for the
+	// workspace and its package loads, eg. GOFLAGS, GOPROXY, GOPRIVATE or
+	// CGO_ENABLED. They override the environment of the server.
+	//
+	// Defaults to empty
+	Env map[string]string
+
+	// GoBinary is the path of the go command run for the workspace. The go
+	// command of the PATH is run if it is empty.
+	//
+	// Defaults to empty
+	GoBinary string
+
+	// SyntheticDirectory is the directory resolving the imports of the
+	// documents which aren't files, such as the untitled buffers of the
+	// editors. A relative path is relative to the outermost workspace
+	// folder.
+	//
+	// Defaults to the outermost workspace folder
+	SyntheticDirectory string
 }
 
 // Apply sets the corresponding field in c for each non-nil field in o.
@@ -54,10 +188,50 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.DiagnosticsStyle = *o.DiagnosticsStyle
 	}
 
+	if o.DiagnosticsDelay != nil {
+		c.DiagnosticsDelay = time.Duration(*o.DiagnosticsDelay) * time.Millisecond
+	}
+
 	if o.GlobalCacheStyle != nil {
 		c.GlobalCacheStyle = *o.GlobalCacheStyle
 	}
 
+	if o.IndexDirectory != nil {
+		c.IndexDirectory = *o.IndexDirectory
+	}
+
+	if o.IncludePatterns != nil {
+		c.IncludePatterns = o.IncludePatterns
+	}
+
+	if o.ExcludePatterns != nil {
+		c.ExcludePatterns = o.ExcludePatterns
+	}
+
+	if o.MaxDepth != nil {
+		c.MaxDepth = *o.MaxDepth
+	}
+
+	if o.UseIgnoreFiles != nil {
+		c.UseIgnoreFiles = *o.UseIgnoreFiles
+	}
+
+	if o.DependencyMode != nil {
+		c.DependencyMode = *o.DependencyMode
+	}
+
+	if o.MemoryLimit != nil {
+		c.MemoryLimit = *o.MemoryLimit
+	}
+
+	if o.WatchMode != nil {
+		c.WatchMode = *o.WatchMode
+	}
+
+	if o.PollInterval != nil {
+		c.PollInterval = time.Duration(*o.PollInterval) * time.Millisecond
+	}
+
 	if o.FormatStyle != nil {
 		c.FormatStyle = *o.FormatStyle
 	}
@@ -74,6 +248,45 @@ func (c Config) Apply(o *InitializationOptions) Config {
 		c.BuildTags = o.BuildTags
 	}
 
+	if o.BuildConfigurations != nil {
+		c.BuildConfigurations = o.BuildConfigurations
+	}
+
+	if o.ReverseDependencyDepth != nil {
+		c.ReverseDependencyDepth = *o.ReverseDependencyDepth
+	}
+
+	if o.ReverseDependencyBudget != nil {
+		c.ReverseDependencyBudget = time.Duration(*o.ReverseDependencyBudget) * time.Millisecond
+	}
+
+	if o.DeprecatedSeverity != nil {
+		c.DeprecatedSeverity = *o.DeprecatedSeverity
+	}
+
+	if o.Analyses != nil {
+		c.Analyses = o.Analyses
+	}
+
+	if o.Env != nil {
+		env := make(map[string]string, len(c.Env)+len(o.Env))
+		for key, value := range c.Env {
+			env[key] = value
+		}
+		for key, value := range o.Env {
+			env[key] = value
+		}
+		c.Env = env
+	}
+
+	if o.GoBinary != nil {
+		c.GoBinary = *o.GoBinary
+	}
+
+	if o.SyntheticDirectory != nil {
+		c.SyntheticDirectory = *o.SyntheticDirectory
+	}
+
 	return c
 }
 
@@ -81,6 +294,15 @@ func (c Config) Apply(o *InitializationOptions) Config {
 // defaults.
 func NewDefaultConfig() Config {
 	return Config{
-		DisableFuncSnippet: false,
+		DisableFuncSnippet:      false,
+		ExcludePatterns:         cache.DefaultExcludePatterns,
+		MaxDepth:                cache.DefaultMaxDepth,
+		DependencyMode:          "source",
+		WatchMode:               "auto",
+		PollInterval:            2 * time.Second,
+		DiagnosticsDelay:        200 * time.Millisecond,
+		ReverseDependencyDepth:  1,
+		ReverseDependencyBudget: 5 * time.Second,
+		DeprecatedSeverity:      "hint",
 	}
 }
@@ -0,0 +1,267 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// configFile is a configuration file, .bingo.json or .bingo.toml, at the root
+// of a workspace folder or of a module. It holds options in the
+// InitializationOptions format, and the options of the modules below it,
+// indexed by their slash-separated directory relative to it.
+type configFile struct {
+	InitializationOptions
+	Modules map[string]*InitializationOptions `json:"modules"`
+}
+
+// readConfigFile reads the configuration file of dir, the first of
+// cache.ConfigFileNames found. It returns nil when dir has none.
+func readConfigFile(dir string) (*configFile, error) {
+	for _, name := range cache.ConfigFileNames {
+		filename := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if filepath.Ext(name) == ".toml" {
+			if data, err = tomlToJSON(data); err != nil {
+				return nil, fmt.Errorf("%s: %s", filename, err)
+			}
+		}
+		var file configFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return &file, nil
+	}
+	return nil, nil
+}
+
+// isConfigFile reports whether filename is a configuration file.
+func isConfigFile(filename string) bool {
+	name := filepath.Base(filename)
+	for _, configFile := range cache.ConfigFileNames {
+		if name == configFile {
+			return true
+		}
+	}
+	return false
+}
+
+// configLayers are the sources of the configuration, by increasing
+// precedence: the defaults set by the flags, the configuration files, the
+// InitializationOptions and the settings of the client.
+type configLayers struct {
+	defaults Config
+	init     *InitializationOptions
+	settings *InitializationOptions
+}
+
+// config returns the configuration with the options of files, by increasing
+// precedence.
+func (l *configLayers) config(files ...*InitializationOptions) Config {
+	c := l.defaults
+	for _, options := range files {
+		c = c.Apply(options)
+	}
+	return c.Apply(l.init).Apply(l.settings)
+}
+
+// setsBuildTags reports whether the InitializationOptions or the settings of
+// the client set the build tags, which then apply to every module.
+func (l *configLayers) setsBuildTags() bool {
+	return l.init != nil && l.init.BuildTags != nil || l.settings != nil && l.settings.BuildTags != nil
+}
+
+// configFile returns the configuration file of dir, read on first use.
+func (f *workspaceFolder) configFile(dir string) *configFile {
+	f.filesMu.Lock()
+	file, ok := f.files[dir]
+	f.filesMu.Unlock()
+	if ok {
+		return file
+	}
+
+	file, err := f.readConfigFile(dir)
+	if err != nil {
+		log.Printf("failed to read the configuration file: %s", err)
+	}
+	return file
+}
+
+// readConfigFile reads the configuration file of dir again. A file which
+// can't be read is cached as missing.
+func (f *workspaceFolder) readConfigFile(dir string) (*configFile, error) {
+	file, err := readConfigFile(dir)
+	f.filesMu.Lock()
+	f.files[dir] = file
+	f.filesMu.Unlock()
+	return file, err
+}
+
+// rootOptions returns the options of the configuration file of the root of
+// the folder, which apply to the whole project.
+func (f *workspaceFolder) rootOptions() []*InitializationOptions {
+	if file := f.configFile(f.rootDir); file != nil {
+		return []*InitializationOptions{&file.InitializationOptions}
+	}
+	return nil
+}
+
+// moduleOptions returns the options of the module rooted at dir, by increasing
+// precedence: its section of the configuration file of the root of the
+// folder, and its own configuration file.
+func (f *workspaceFolder) moduleOptions(dir string) []*InitializationOptions {
+	if dir == f.rootDir {
+		return nil
+	}
+
+	var options []*InitializationOptions
+	if root := f.configFile(f.rootDir); root != nil {
+		if rel, err := filepath.Rel(f.rootDir, dir); err == nil {
+			if section := root.Modules[filepath.ToSlash(rel)]; section != nil {
+				options = append(options, section)
+			}
+		}
+	}
+	if file := f.configFile(dir); file != nil {
+		options = append(options, &file.InitializationOptions)
+	}
+	return options
+}
+
+// fileOptions returns the options of the configuration files which apply to
+// the packages of dir, by increasing precedence.
+func (f *workspaceFolder) fileOptions(dir string) []*InitializationOptions {
+	options := f.rootOptions()
+	if moduleDir := f.project.ModuleRoot(dir); moduleDir != "" {
+		options = append(options, f.moduleOptions(moduleDir)...)
+	}
+	return options
+}
+
+// moduleTags returns the build tags set by the configuration files for the
+// module rooted at dir.
+func (f *workspaceFolder) moduleTags(dir string) ([]string, bool) {
+	var tags []string
+	found := false
+	for _, options := range f.moduleOptions(dir) {
+		if options.BuildTags != nil {
+			tags, found = options.BuildTags, true
+		}
+	}
+	return tags, found
+}
+
+// contains reports whether dir is the root of the folder or below it.
+func (f *workspaceFolder) contains(dir string) bool {
+	return dir == f.rootDir || strings.HasPrefix(filepath.ToSlash(dir), filepath.ToSlash(f.rootDir)+"/")
+}
+
+// moduleTagsFunc returns how the project of folder finds the build tags of
+// its modules: the ones set by their configuration files, unless the
+// InitializationOptions or the settings of the client set the build tags.
+func (h *overlay) moduleTagsFunc(folder *workspaceFolder) cache.ModuleTagsFunc {
+	return func(dir string) ([]string, bool) {
+		if layers := h.getLayers(); layers.setsBuildTags() {
+			return nil, false
+		}
+		return folder.moduleTags(dir)
+	}
+}
+
+// folderConfig returns the configuration of the project of folder.
+func (h *overlay) folderConfig(folder *workspaceFolder) *Config {
+	layers := h.getLayers()
+	config := layers.config(folder.rootOptions()...)
+	return &config
+}
+
+// configFor returns the configuration of the file uri: the active one, with
+// the options of the configuration files of its folder and of its module.
+func (h *overlay) configFor(uri span.URI) *Config {
+	folder := h.folders.folderFor(uri)
+	filename, err := uri.Filename()
+	if folder == nil || err != nil {
+		return h.getConfig()
+	}
+
+	layers := h.getLayers()
+	config := layers.config(folder.fileOptions(util.LowerDriver(filepath.Dir(filename)))...)
+	return &config
+}
+
+// didChangeConfigFile reads the configuration file filename of a workspace
+// folder again. A change of the build tags or of the global cache style of the
+// project type-checks it again in the background, as does a change of the
+// build tags of its modules. The options read by the requests apply at once.
+func (h *LangHandler) didChangeConfigFile(ctx context.Context, conn jsonrpc2.JSONRPC2, filename string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dir := util.LowerDriver(filepath.Dir(filename))
+	folder := h.folders.folderFor(span.FileURI(filename))
+	if folder == nil || !folder.contains(dir) {
+		return
+	}
+
+	old := h.overlay.folderConfig(folder)
+	oldFile := folder.configFile(dir)
+	file, err := folder.readConfigFile(dir)
+	if err != nil {
+		h.notifyError(fmt.Sprintf("failed to read the configuration file: %s", err))
+	}
+	if reflect.DeepEqual(oldFile, file) {
+		return
+	}
+
+	config := h.overlay.folderConfig(folder)
+	flags := buildFlags(config)
+	if !reflect.DeepEqual(buildFlags(old), flags) || !reflect.DeepEqual(goEnv(old), goEnv(config)) || old.GlobalCacheStyle != config.GlobalCacheStyle {
+		folder.project.Reconfigure(flags, goEnv(config), cache.CacheStyle(config.GlobalCacheStyle))
+	} else if !reflect.DeepEqual(configFileTags(oldFile, dir == folder.rootDir), configFileTags(file, dir == folder.rootDir)) {
+		folder.project.Reload()
+	}
+
+	if changed := projectOptionsChanged(old, config); len(changed) > 0 {
+		message := fmt.Sprintf("the change of %s in the configuration file of %s applies once the folder is opened again", strings.Join(changed, ", "), folder.rootDir)
+		_ = conn.Notify(ctx, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: message})
+	}
+}
+
+// configFileTags returns the build tags that the configuration file sets for
+// modules, indexed by their directory relative to it: the ones of its module
+// sections for the file of the root of a folder, else its own.
+func configFileTags(file *configFile, root bool) map[string][]string {
+	tags := make(map[string][]string)
+	if file == nil {
+		return tags
+	}
+	if !root {
+		tags["."] = file.BuildTags
+		return tags
+	}
+	for dir, options := range file.Modules {
+		if options != nil {
+			tags[dir] = options.BuildTags
+		}
+	}
+	return tags
+}
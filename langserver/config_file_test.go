@@ -0,0 +1,72 @@
+package langserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write := func(name, content string) {
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".bingo.json", `{"buildTags": ["root"], "formatStyle": "goimports", "modules": {"api": {"buildTags": ["api"]}, "cmd": {"maxDepth": 2}}}`)
+	write(".bingo.toml", `buildTags = ["ignored"]`)
+	write("api/.bingo.toml", `goimportsLocalPrefix = "example.com/api"`)
+	write("cmd/.bingo.toml", "buildTags = [\"cmd\"]\nformatStyle = \"gofmt\"")
+	write("bad/.bingo.json", `{"buildTags": "bad"}`)
+
+	folder := newWorkspaceFolder(root)
+	tests := []struct {
+		dir  string
+		tags []string
+		ok   bool
+	}{
+		{root, nil, false},
+		{filepath.Join(root, "api"), []string{"api"}, true},
+		// The file of the module takes precedence over its section.
+		{filepath.Join(root, "cmd"), []string{"cmd"}, true},
+		{filepath.Join(root, "lib"), nil, false},
+	}
+	for _, test := range tests {
+		tags, ok := folder.moduleTags(test.dir)
+		if !reflect.DeepEqual(tags, test.tags) || ok != test.ok {
+			t.Errorf("moduleTags(%s) = %v, %t, want %v, %t", test.dir, tags, ok, test.tags, test.ok)
+		}
+	}
+	if _, err := folder.readConfigFile(filepath.Join(root, "bad")); err == nil {
+		t.Errorf("read an invalid configuration file")
+	}
+
+	// flags < file < InitializationOptions < settings
+	defaults := NewDefaultConfig()
+	defaults.FormatStyle = "gofmt"
+	defaults.MaxDepth = 8
+	prefix, maxDepth := "example.com/init", 6
+	layers := configLayers{
+		defaults: defaults,
+		init:     &InitializationOptions{GoimportsLocalPrefix: &prefix},
+		settings: &InitializationOptions{MaxDepth: &maxDepth},
+	}
+	config := layers.config(append(folder.rootOptions(), folder.moduleOptions(filepath.Join(root, "api"))...)...)
+	if config.FormatStyle != goimportsStyle || config.GoimportsLocalPrefix != prefix || config.MaxDepth != maxDepth || !reflect.DeepEqual(config.BuildTags, []string{"api"}) {
+		t.Errorf("got formatStyle %q, goimportsLocalPrefix %q, maxDepth %d, buildTags %v", config.FormatStyle, config.GoimportsLocalPrefix, config.MaxDepth, config.BuildTags)
+	}
+	if layers.setsBuildTags() {
+		t.Errorf("the build tags are not set by the InitializationOptions or the settings")
+	}
+}
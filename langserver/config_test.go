@@ -0,0 +1,26 @@
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigApplyEnv(t *testing.T) {
+	t.Parallel()
+
+	c := NewDefaultConfig()
+	c.Env = map[string]string{"GOPROXY": "off", "CGO_ENABLED": "0"}
+	goBinary := "/opt/go1.12/bin/go"
+	c = c.Apply(&InitializationOptions{Env: map[string]string{"GOFLAGS": "-mod=vendor", "CGO_ENABLED": "1"}, GoBinary: &goBinary})
+
+	want := map[string]string{"GOPROXY": "off", "CGO_ENABLED": "1", "GOFLAGS": "-mod=vendor"}
+	if !reflect.DeepEqual(c.Env, want) {
+		t.Errorf("got env %v, want %v", c.Env, want)
+	}
+	if got, want := goEnv(&c), []string{"CGO_ENABLED=1", "GOFLAGS=-mod=vendor", "GOPROXY=off"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("goEnv = %v, want %v", got, want)
+	}
+	if c.GoBinary != goBinary {
+		t.Errorf("got go binary %q, want %q", c.GoBinary, goBinary)
+	}
+}
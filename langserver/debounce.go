@@ -0,0 +1,80 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// debouncer runs work per key once the key has stayed quiet for a delay.
+// Scheduling a key again replaces the run pending for it and cancels the one
+// still in flight.
+type debouncer struct {
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	running map[string]*debouncedRun
+}
+
+type debouncedRun struct {
+	cancel context.CancelFunc
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{
+		pending: make(map[string]*time.Timer),
+		running: make(map[string]*debouncedRun),
+	}
+}
+
+// schedule runs the work of key after delay. When the delay expires, prepare
+// returns the context the run is bound to and the work, which is called with
+// a context also cancelled by the next schedule of key.
+func (d *debouncer) schedule(key string, delay time.Duration, prepare func() (context.Context, func(context.Context))) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.pending[key]; ok {
+		t.Stop()
+	}
+	if run, ok := d.running[key]; ok {
+		run.cancel()
+		delete(d.running, key)
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		if d.pending[key] != t {
+			// The timer fired while it was replaced.
+			d.mu.Unlock()
+			return
+		}
+		delete(d.pending, key)
+		d.mu.Unlock()
+
+		ctx, work := prepare()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		run := &debouncedRun{cancel: cancel}
+		d.mu.Lock()
+		if _, ok := d.pending[key]; ok {
+			// The key was scheduled again while the run was prepared.
+			d.mu.Unlock()
+			return
+		}
+		d.running[key] = run
+		d.mu.Unlock()
+
+		defer func() {
+			d.mu.Lock()
+			if d.running[key] == run {
+				delete(d.running, key)
+			}
+			d.mu.Unlock()
+		}()
+
+		work(ctx)
+	})
+	d.pending[key] = t
+}
@@ -0,0 +1,131 @@
+package langserver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebouncerBurst(t *testing.T) {
+	t.Parallel()
+
+	d := newDebouncer()
+	var mu sync.Mutex
+	var runs []int
+	done := make(chan struct{}, 10)
+	for i := 0; i < 5; i++ {
+		i := i
+		d.schedule("dir", 20*time.Millisecond, func() (context.Context, func(context.Context)) {
+			return context.Background(), func(context.Context) {
+				mu.Lock()
+				runs = append(runs, i)
+				mu.Unlock()
+				done <- struct{}{}
+			}
+		})
+	}
+
+	<-done
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) != 1 || runs[0] != 4 {
+		t.Errorf("got runs %v, want only the last one", runs)
+	}
+}
+
+func TestDebouncerCancelsRunningWork(t *testing.T) {
+	t.Parallel()
+
+	d := newDebouncer()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	d.schedule("dir", 0, func() (context.Context, func(context.Context)) {
+		return context.Background(), func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+		}
+	})
+	<-started
+
+	second := make(chan struct{})
+	d.schedule("dir", 0, func() (context.Context, func(context.Context)) {
+		return context.Background(), func(ctx context.Context) {
+			if ctx.Err() != nil {
+				t.Errorf("second run started cancelled")
+			}
+			close(second)
+		}
+	})
+
+	for _, c := range []chan struct{}{cancelled, second} {
+		select {
+		case <-c:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestDebouncerKeepsNewerPendingRun(t *testing.T) {
+	t.Parallel()
+
+	d := newDebouncer()
+	fired := make(chan struct{})
+	release := make(chan struct{})
+	// The first timer fires and blocks in prepare, then the key is
+	// scheduled again: the first run must neither drop the second timer
+	// nor run its work.
+	d.schedule("dir", 0, func() (context.Context, func(context.Context)) {
+		close(fired)
+		<-release
+		return context.Background(), func(context.Context) {
+			t.Errorf("replaced run was started")
+		}
+	})
+	<-fired
+
+	ran := make(chan struct{})
+	d.schedule("dir", time.Hour, func() (context.Context, func(context.Context)) {
+		return context.Background(), func(context.Context) { close(ran) }
+	})
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+
+	d.mu.Lock()
+	_, pending := d.pending["dir"]
+	d.mu.Unlock()
+	if !pending {
+		t.Errorf("the newer run is no longer pending")
+	}
+}
+
+func TestDebouncerSkipsReplacedTimer(t *testing.T) {
+	t.Parallel()
+
+	d := newDebouncer()
+	prepared := make(chan struct{}, 1)
+	d.schedule("dir", time.Hour, func() (context.Context, func(context.Context)) {
+		prepared <- struct{}{}
+		return context.Background(), func(context.Context) {}
+	})
+
+	// The timer fires while the key is scheduled again: the replaced timer
+	// must not prepare its run, which snapshots the documents.
+	next := time.AfterFunc(time.Hour, func() {})
+	defer next.Stop()
+	d.mu.Lock()
+	d.pending["dir"].Reset(0)
+	time.Sleep(10 * time.Millisecond)
+	d.pending["dir"] = next
+	d.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-prepared:
+		t.Error("the replaced timer prepared its run")
+	default:
+	}
+}
@@ -123,7 +123,7 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 			//
 			// TODO(sqs): find a way to actually emit builtin locations
 			// (pointing to builtin/builtin.go).
-			pkg = h.project.GetBuiltinPackage()
+			pkg = h.projectOf(pkg).GetBuiltinPackage()
 			if pkg == nil {
 				return []symbolLocationInformation{}, nil
 			}
@@ -159,7 +159,7 @@ func (h *LangHandler) lookupIdentDefinition(ctx context.Context, conn jsonrpc2.J
 
 		// Determine metadata information for the ident.
 		if def, err := refs.DefInfo(pkg.GetTypes(), pkg.GetTypesInfo(), pathNodes, found.ident.Pos()); err == nil {
-			symDesc, err := defSymbolDescriptor(pkg, h.project, *def, findPackage)
+			symDesc, err := defSymbolDescriptor(pkg, h.projectOf(pkg), *def, findPackage)
 			if err != nil {
 				// TODO: tracing
 				//log.Println("refs.DefInfo:", err)
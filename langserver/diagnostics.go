@@ -7,27 +7,93 @@ package langserver
 import (
 	"context"
 	"fmt"
+	"go/ast"
 	"go/token"
+	"go/types"
 	"strconv"
 	"strings"
 
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/sourcegraph/go-lsp"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
+// compilerSource is the source of the parse and type errors.
+const compilerSource = "LSP: Go compiler"
+
 // NOTICE: Code adapted from https://github.com/golang/tools/blob/master/internal/lsp/diagnostics.go.
 
-func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnostic, error) {
+func (h *overlay) diagnostics(ctx context.Context, f source.File) (map[string][]protocol.Diagnostic, error) {
 	pkg := f.GetPackage(ctx)
 	if pkg == nil {
 		return nil, fmt.Errorf("package is null for file")
 	}
 
-	reports := make(map[string][]lsp.Diagnostic)
+	return configurationDiagnostics(ctx, h.projectFor(f.URI()), pkg, h.configFor(f.URI()), h.configurations), nil
+}
+
+// packageDiagnostics computes the diagnostics of every file of pkg. It is
+// shared by the editor and the workspace checks, so that both report exactly
+// the same problems.
+func packageDiagnostics(ctx context.Context, pkg source.Package, config *Config) map[string][]protocol.Diagnostic {
+	reports, ok := compilerDiagnostics(pkg)
+	if !ok {
+		return reports
+	}
+
+	// Type checking and parsing succeeded. Report the uses of deprecated
+	// symbols, then run the analyses of the configuration.
+	fset := pkg.GetFileSet()
+	if severity, ok := deprecatedSeverity(config); ok {
+		source.DeprecatedUses(pkg, func(ident *ast.Ident, o types.Object, notice string) {
+			start, end := fset.Position(ident.Pos()), fset.Position(ident.End())
+			if _, ok := reports[start.Filename]; !ok {
+				return
+			}
+			reports[start.Filename] = append(reports[start.Filename], protocol.Diagnostic{
+				Diagnostic: lsp.Diagnostic{
+					Range: lsp.Range{
+						Start: lsp.Position{Line: start.Line - 1, Character: start.Column - 1},
+						End:   lsp.Position{Line: end.Line - 1, Character: end.Column - 1},
+					},
+					Severity: severity,
+					Source:   "deprecated",
+					Message:  fmt.Sprintf("%s is deprecated: %s", o.Name(), notice),
+				},
+				Tags: []protocol.DiagnosticTag{protocol.Deprecated},
+			})
+		})
+	}
+
+	analyzers := configAnalyzers(config)
+	if len(analyzers) == 0 {
+		return reports
+	}
+	_ = source.RunAnalyses(ctx, fset, pkg, analyzers, func(a *analysis.Analyzer, diag analysis.Diagnostic) {
+		pos := fset.Position(diag.Pos)
+		if _, ok := reports[pos.Filename]; !ok {
+			return
+		}
+		start := lsp.Position{Line: pos.Line - 1, Character: pos.Column - 1}
+		reports[pos.Filename] = append(reports[pos.Filename], protocol.Diagnostic{Diagnostic: lsp.Diagnostic{
+			Range:    lsp.Range{Start: start, End: start},
+			Severity: lsp.Warning,
+			Source:   a.Name,
+			Message:  diag.Message,
+		}})
+	})
+	return reports
+}
+
+// compilerDiagnostics returns the parse or type errors of every file of pkg,
+// and whether pkg is well typed enough to be analyzed.
+func compilerDiagnostics(pkg source.Package) (map[string][]protocol.Diagnostic, bool) {
+	reports := make(map[string][]protocol.Diagnostic)
 	for _, filename := range pkg.GetFilenames() {
-		reports[filename] = []lsp.Diagnostic{}
+		reports[filename] = []protocol.Diagnostic{}
 	}
 	var parseErrors, typeErrors []packages.Error
 	for _, err := range pkg.GetErrors() {
@@ -50,7 +116,7 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 		pos := parseErrorPos(err)
 		line := pos.Line - 1
 		col := pos.Column - 1
-		diagnostic := lsp.Diagnostic{
+		diagnostic := protocol.Diagnostic{Diagnostic: lsp.Diagnostic{
 			// TODO(rstambler): Add support for diagnostic ranges.
 			Range: lsp.Range{
 				Start: lsp.Position{
@@ -63,14 +129,55 @@ func diagnostics(ctx context.Context, f source.File) (map[string][]lsp.Diagnosti
 				},
 			},
 			Severity: lsp.Error,
-			Source:   "LSP: Go compiler",
+			Source:   compilerSource,
 			Message:  err.Msg,
-		}
+		}}
 		if _, ok := reports[pos.Filename]; ok {
 			reports[pos.Filename] = append(reports[pos.Filename], diagnostic)
 		}
 	}
-	return reports, nil
+	return reports, len(errors) == 0 && !pkg.IsIllTyped() && pkg.GetFileSet() != nil
+}
+
+// configAnalyzers returns the analyzers of the vet suite named by the
+// analyses of config.
+func configAnalyzers(config *Config) []*analysis.Analyzer {
+	if config == nil {
+		return nil
+	}
+
+	names := make(map[string]bool, len(config.Analyses))
+	for _, name := range config.Analyses {
+		names[name] = true
+	}
+	var analyzers []*analysis.Analyzer
+	for _, a := range source.VetAnalyzers {
+		if names["all"] || names[a.Name] {
+			analyzers = append(analyzers, a)
+		}
+	}
+	return analyzers
+}
+
+// deprecatedSeverity returns the severity of the diagnostics on uses of
+// deprecated symbols, and false if they are disabled.
+func deprecatedSeverity(config *Config) (lsp.DiagnosticSeverity, bool) {
+	if config == nil {
+		return lsp.Hint, true
+	}
+
+	switch config.DeprecatedSeverity {
+	case "none":
+		return 0, false
+	case "info", "information":
+		return lsp.Information, true
+	case "warning":
+		return lsp.Warning, true
+	case "error":
+		return lsp.Error, true
+	default:
+		return lsp.Hint, true
+	}
 }
 
 func parseErrorPos(pkgErr packages.Error) (pos token.Position) {
@@ -0,0 +1,70 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// diagnosticsScheduler computes the diagnostics of edited packages in the
+// background, so that typing doesn't pile up type-checks in front of the
+// following requests. Runs are debounced per package directory, and a run is
+// cancelled as soon as a newer version of a document arrives, since the view
+// replaces its background context on every content change.
+type diagnosticsScheduler struct {
+	overlay   *overlay
+	debouncer *debouncer
+
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func newDiagnosticsScheduler(overlay *overlay, debouncer *debouncer, delay time.Duration) *diagnosticsScheduler {
+	return &diagnosticsScheduler{
+		overlay:   overlay,
+		debouncer: debouncer,
+		delay:     delay,
+	}
+}
+
+// setDelay changes the delay of the runs scheduled from now on.
+func (s *diagnosticsScheduler) setDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+// schedule queues a diagnostics run for the package containing uri,
+// replacing the run already queued or in flight for the same package.
+func (s *diagnosticsScheduler) schedule(uri span.URI) {
+	filename, err := uri.Filename()
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+
+	s.debouncer.schedule("diagnostics:"+filepath.Dir(filename), delay, func() (context.Context, func(context.Context)) {
+		ctx, versions := s.overlay.snapshot(uri)
+		return ctx, func(ctx context.Context) {
+			s.run(ctx, uri, versions)
+		}
+	})
+}
+
+func (s *diagnosticsScheduler) run(ctx context.Context, uri span.URI, versions map[string]int) {
+	f, err := s.overlay.viewFor(uri).GetFile(ctx, uri)
+	if err != nil {
+		return
+	}
+	reports, err := s.overlay.diagnostics(ctx, f)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	s.overlay.publishDiagnostics(ctx, reports, versions)
+}
@@ -0,0 +1,16 @@
+package langserver
+
+import "github.com/saibing/bingo/langserver/internal/cache"
+
+// IsPackagesDriver reports whether the server runs as the packages driver of
+// a workspace folder whose go command is set by the goBinary option.
+func IsPackagesDriver() bool {
+	return cache.IsPackagesDriver()
+}
+
+// RunPackagesDriver lists the packages of the patterns for a workspace folder
+// whose go command is set by the goBinary option, see
+// cache.RunPackagesDriver.
+func RunPackagesDriver(patterns []string) error {
+	return cache.RunPackagesDriver(patterns)
+}
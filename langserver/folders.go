@@ -0,0 +1,149 @@
+package langserver
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// workspaceFolders are the projects of the folders of the workspace, one per
+// folder. They share the dependencies outside of the folders.
+type workspaceFolders struct {
+	deps *cache.DependencyCache
+
+	mu      sync.RWMutex
+	folders []*workspaceFolder
+}
+
+// workspaceFolder is a folder of the workspace and its project.
+type workspaceFolder struct {
+	rootDir string
+	project *cache.Project
+	// cancel stops the background tasks of the project.
+	cancel context.CancelFunc
+
+	// files caches the configuration files of the root of the folder and of
+	// its modules, by directory. A nil file is a directory without one.
+	filesMu sync.Mutex
+	files   map[string]*configFile
+}
+
+func newWorkspaceFolders() *workspaceFolders {
+	return &workspaceFolders{deps: cache.NewDependencyCache()}
+}
+
+func newWorkspaceFolder(rootDir string) *workspaceFolder {
+	return &workspaceFolder{rootDir: rootDir, files: make(map[string]*configFile)}
+}
+
+// add adds the folder, whose project and cancel function must be set: the
+// background tasks of the project are stopped by cancel when the folder is
+// removed. It reports false if the folder is already in the workspace.
+func (w *workspaceFolders) add(folder *workspaceFolder) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, f := range w.folders {
+		if f.rootDir == folder.rootDir {
+			return false
+		}
+	}
+	w.folders = append(w.folders, folder)
+	// The innermost folders come first, so that they own their files.
+	sort.SliceStable(w.folders, func(i, j int) bool {
+		return len(w.folders[i].rootDir) > len(w.folders[j].rootDir)
+	})
+	return true
+}
+
+// remove removes the folder rootDir, and stops its project.
+func (w *workspaceFolders) remove(rootDir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, folder := range w.folders {
+		if folder.rootDir == rootDir {
+			folder.cancel()
+			w.folders = append(w.folders[:i], w.folders[i+1:]...)
+			return
+		}
+	}
+}
+
+// list returns the folders, the innermost ones first.
+func (w *workspaceFolders) list() []*workspaceFolder {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return append([]*workspaceFolder(nil), w.folders...)
+}
+
+// projects returns the projects of the folders.
+func (w *workspaceFolders) projects() []*cache.Project {
+	folders := w.list()
+	projects := make([]*cache.Project, len(folders))
+	for i, folder := range folders {
+		projects[i] = folder.project
+	}
+	return projects
+}
+
+// projectFor returns the project of the folder of the file uri, see
+// folderFor. It is nil when the workspace has no folder.
+func (w *workspaceFolders) projectFor(uri span.URI) *cache.Project {
+	if folder := w.folderFor(uri); folder != nil {
+		return folder.project
+	}
+	return nil
+}
+
+// folderFor returns the folder of the file uri: the innermost one containing
+// it, or else the first one whose project loaded it, since the files of the
+// dependencies are outside of the folders. It is nil when the workspace has
+// no folder.
+func (w *workspaceFolders) folderFor(uri span.URI) *workspaceFolder {
+	folders := w.list()
+	if len(folders) == 0 {
+		return nil
+	}
+
+	filename, err := uri.Filename()
+	if err != nil {
+		return folders[0]
+	}
+	for _, folder := range folders {
+		if folder.project.IsInside(filename) {
+			return folder
+		}
+	}
+	for _, folder := range folders {
+		if folder.project.Cache().GetByURI(filename) != nil {
+			return folder
+		}
+	}
+	return folders[0]
+}
+
+// loading reports whether a project of the workspace is still loading.
+func (w *workspaceFolders) loading() bool {
+	for _, project := range w.projects() {
+		if project.Loading() {
+			return true
+		}
+	}
+	return false
+}
+
+// needsFolder reports whether the request method is served by the project of
+// a workspace folder.
+func needsFolder(method string) bool {
+	switch method {
+	case "initialize", "initialized", "shutdown", "exit", "$/cancelRequest", "workspace/didChangeWorkspaceFolders", "workspace/didChangeConfiguration":
+		return false
+	}
+	return strings.HasPrefix(method, "textDocument/") || strings.HasPrefix(method, "workspace/")
+}
@@ -0,0 +1,64 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+func TestWorkspaceFolders(t *testing.T) {
+	folders := newWorkspaceFolders()
+	if project := folders.projectFor(span.FileURI("/ws/a.go")); project != nil {
+		t.Fatalf("got a project without folders")
+	}
+
+	newProject := func(rootDir string) *cache.Project {
+		project := cache.NewProject(context.Background(), nil, rootDir, nil)
+		folder := newWorkspaceFolder(rootDir)
+		folder.project, folder.cancel = project, func() {}
+		if !folders.add(folder) {
+			t.Fatalf("folder %s not added", rootDir)
+		}
+		return project
+	}
+	outer := newProject("/ws")
+	inner := newProject("/ws/inner")
+	other := newProject("/other")
+	folder := newWorkspaceFolder("/ws")
+	folder.project, folder.cancel = outer, func() {}
+	if folders.add(folder) {
+		t.Errorf("folder /ws added twice")
+	}
+
+	tests := []struct {
+		filename string
+		want     *cache.Project
+		folder   string
+	}{
+		{"/ws/a.go", outer, "/ws"},
+		{"/ws/inner/b.go", inner, "/ws/inner"},
+		{"/other/c.go", other, "/other"},
+		// The files outside of the folders fall back to the first folder.
+		{"/dep/d.go", inner, "/ws/inner"},
+	}
+	for _, test := range tests {
+		if got := folders.projectFor(span.FileURI(test.filename)); got != test.want {
+			t.Errorf("projectFor(%s) is not the project of %s", test.filename, test.folder)
+		}
+	}
+
+	cancelled := false
+	folders.remove("/ws/inner")
+	folder = newWorkspaceFolder("/ws/inner")
+	folder.project, folder.cancel = inner, func() { cancelled = true }
+	folders.add(folder)
+	folders.remove("/ws/inner")
+	if !cancelled {
+		t.Errorf("the project of the removed folder is not stopped")
+	}
+	if got := folders.projectFor(span.FileURI("/ws/inner/b.go")); got != outer {
+		t.Errorf("projectFor(/ws/inner/b.go) is not the project of /ws after the removal of /ws/inner")
+	}
+}
@@ -21,15 +21,16 @@ const (
 )
 
 func (h *LangHandler) handleTextDocumentFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, nil, h.DefaultConfig.FormatStyle == goimportsStyle)
+	return formatRange(ctx, h.viewFor(params.TextDocument.URI), params.TextDocument.URI, nil, h.configFor(params.TextDocument.URI))
 }
 
 func (h *LangHandler) handleTextDocumentRangeFormatting(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.DocumentRangeFormattingParams) ([]lsp.TextEdit, error) {
-	return formatRange(ctx, h.View(), params.TextDocument.URI, &params.Range, h.DefaultConfig.FormatStyle == goimportsStyle)
+	return formatRange(ctx, h.viewFor(params.TextDocument.URI), params.TextDocument.URI, &params.Range, h.configFor(params.TextDocument.URI))
 }
 
-// formatRange formats a document with a given range.
-func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *lsp.Range, imports bool) ([]lsp.TextEdit, error) {
+// formatRange formats a document with a given range, in the format style of
+// config.
+func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *lsp.Range, config *Config) ([]lsp.TextEdit, error) {
 	sourceURI, err := fromProtocolURI(uri)
 	if err != nil {
 		return nil, err
@@ -51,8 +52,8 @@ func formatRange(ctx context.Context, v source.View, uri lsp.DocumentURI, rng *l
 	}
 
 	var edits []source.TextEdit
-	if imports {
-		edits, err = source.Imports(ctx, f, r)
+	if config.FormatStyle == goimportsStyle {
+		edits, err = source.Imports(ctx, f, r, config.GoimportsLocalPrefix)
 	} else {
 		edits, err = source.Format(ctx, f, r)
 	}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"path/filepath"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/span"
 	lsp "github.com/sourcegraph/go-lsp"
@@ -88,21 +91,92 @@ func (h *HandlerShared) handleFileSystemRequest(ctx context.Context, req *jsonrp
 // overlay owns the overlay filesystem, as well as handling LSP filesystem
 // requests.
 type overlay struct {
-	conn             *jsonrpc2.Conn
-	project          *cache.Project
-	diagnosticsStyle DiagnosticsStyleEnum
+	conn        *jsonrpc2.Conn
+	folders     *workspaceFolders
+	scheduler   *diagnosticsScheduler
+	reverseDeps *reverseDiagnostics
+
+	// configurations are the errors of the packages under the build
+	// configurations, computed again on save.
+	configurations *configurationReports
+
+	// config is the active configuration, which the client may change. It
+	// is replaced, never modified. layers are its sources: the configuration
+	// files of the folders only apply to their files, see configFor.
+	configMu sync.RWMutex
+	config   *Config
+	layers   configLayers
+
+	// versions maps the filenames of the opened documents to their version.
+	// mu also serializes the content changes, so that a version is always
+	// read along with the view context of its content, see snapshot.
+	mu       sync.Mutex
+	versions map[string]int
+
+	// synthetic are the opened documents which aren't files.
+	synthetic *syntheticDocuments
 }
 
-func newOverlay(conn *jsonrpc2.Conn, project *cache.Project, diagnosticsStyle DiagnosticsStyleEnum) *overlay {
-	return &overlay{conn: conn, project: project, diagnosticsStyle: diagnosticsStyle}
+func newOverlay(conn *jsonrpc2.Conn, folders *workspaceFolders, layers configLayers) *overlay {
+	config := layers.config()
+	o := &overlay{conn: conn, folders: folders, config: &config, layers: layers, versions: make(map[string]int), synthetic: newSyntheticDocuments(), configurations: newConfigurationReports()}
+	// The runs of both schedulers are keyed by kind and package directory,
+	// so that they don't replace each other.
+	debouncer := newDebouncer()
+	o.scheduler = newDiagnosticsScheduler(o, debouncer, config.DiagnosticsDelay)
+	o.reverseDeps = newReverseDiagnostics(o, debouncer, config.ReverseDependencyDepth, config.ReverseDependencyBudget)
+	return o
 }
 
-func (h *overlay) view() source.View {
-	return h.project.View()
+// getConfig returns the active configuration.
+func (h *overlay) getConfig() *Config {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.config
+}
+
+// getLayers returns the sources of the active configuration.
+func (h *overlay) getLayers() configLayers {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.layers
+}
+
+// setLayers replaces the sources of the active configuration. The
+// diagnostics scheduled from now on follow the new one.
+func (h *overlay) setLayers(layers configLayers) {
+	config := layers.config()
+	h.configMu.Lock()
+	h.config = &config
+	h.layers = layers
+	h.configMu.Unlock()
+
+	h.scheduler.setDelay(config.DiagnosticsDelay)
+	h.reverseDeps.setLimits(config.ReverseDependencyDepth, config.ReverseDependencyBudget)
+}
+
+// diagnosticsStyle returns when the diagnostics of the file uri are
+// published.
+func (h *overlay) diagnosticsStyle(uri span.URI) DiagnosticsStyleEnum {
+	return DiagnosticsStyleEnum(h.configFor(uri).DiagnosticsStyle)
+}
+
+// projectFor returns the project of the workspace folder of the file uri.
+func (h *overlay) projectFor(uri span.URI) *cache.Project {
+	return h.folders.projectFor(uri)
+}
+
+// viewFor returns the view type-checking the file uri.
+func (h *overlay) viewFor(uri span.URI) source.View {
+	return h.projectFor(uri).ViewFor(uri)
 }
 
 func (h *overlay) didOpen(ctx context.Context, params *lsp.DidOpenTextDocumentParams) {
-	h.cacheAndDiagnose(ctx, params.TextDocument.URI, []byte(params.TextDocument.Text))
+	uri := span.FromDocumentURI(params.TextDocument.URI)
+	if filename, err := uri.Filename(); err == nil {
+		h.projectFor(uri).DidOpen(filename)
+	}
+	h.cacheAndDiagnose(ctx, params.TextDocument.URI, params.TextDocument.Version, []byte(params.TextDocument.Text))
 }
 
 func (h *overlay) didChange(ctx context.Context, params *lsp.DidChangeTextDocumentParams) error {
@@ -115,45 +189,91 @@ func (h *overlay) didChange(ctx context.Context, params *lsp.DidChangeTextDocume
 		return err
 	}
 
-	h.cacheAndDiagnose(ctx, params.TextDocument.URI, text)
+	h.cacheAndDiagnose(ctx, params.TextDocument.URI, params.TextDocument.Version, text)
+	if uri := span.FromDocumentURI(params.TextDocument.URI); h.diagnosticsStyle(uri) == instantDiagnostics {
+		h.reverseDeps.schedule(uri)
+	}
 	return nil
 }
 
 func (h *overlay) didClose(ctx context.Context, params *lsp.DidCloseTextDocumentParams) {
 	uri := span.FromDocumentURI(params.TextDocument.URI)
-	h.setContent(ctx, uri, nil)
+	filename, err := uri.Filename()
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	delete(h.versions, filename)
+	h.viewFor(uri).SetContent(ctx, uri, nil)
+	h.mu.Unlock()
+	h.projectFor(uri).DidClose(filename)
+	h.configurations.saved(filepath.Dir(filename))
 }
 
+// didSave queues the diagnostics of the package of the saved document, with
+// on save diagnostics. Its errors under the build configurations are
+// computed again, with instant diagnostics too.
 func (h *overlay) didSave(ctx context.Context, param *lsp.DidSaveTextDocumentParams) {
-	if h.diagnosticsStyle != onsaveDiagnostics {
-		return
+	sourceURI := span.FromDocumentURI(param.TextDocument.URI)
+	if filename, err := sourceURI.Filename(); err == nil {
+		h.configurations.saved(filepath.Dir(filename))
 	}
 
-	sourceURI := span.FromDocumentURI(param.TextDocument.URI)
-	f, err := h.view().GetFile(ctx, sourceURI)
-	if err != nil {
-		log.Fatal(err)
-		return
+	switch h.diagnosticsStyle(sourceURI) {
+	case onsaveDiagnostics:
+		h.scheduler.schedule(sourceURI)
+		h.reverseDeps.schedule(sourceURI)
+	case instantDiagnostics:
+		if len(h.configFor(sourceURI).BuildConfigurations) > 0 {
+			h.scheduler.schedule(sourceURI)
+		}
 	}
-	h.diagnosetics(ctx, f)
 }
 
-func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, text []byte) {
+// cacheAndDiagnose updates the content of the document and, with instant
+// diagnostics, queues the computation of its package diagnostics in the
+// background.
+func (h *overlay) cacheAndDiagnose(ctx context.Context, uri lsp.DocumentURI, version int, text []byte) {
 	sourceURI := span.FromDocumentURI(uri)
-	h.setContent(ctx, sourceURI, text)
-	f, err := h.view().GetFile(ctx, sourceURI)
-	if err != nil {
+	h.route(ctx, sourceURI, text)
+	h.setContent(ctx, sourceURI, version, text)
+	if h.diagnosticsStyle(sourceURI) != instantDiagnostics {
 		return
 	}
-	if h.diagnosticsStyle != instantDiagnostics {
-		return
+
+	h.scheduler.schedule(sourceURI)
+}
+
+// setContent replaces the content of the opened document uri and its
+// version.
+func (h *overlay) setContent(ctx context.Context, uri span.URI, version int, content []byte) error {
+	filename, err := uri.Filename()
+	if err != nil {
+		return err
 	}
 
-	go h.diagnosetics(ctx, f)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.versions[filename] = version
+	return h.viewFor(uri).SetContent(ctx, uri, content)
 }
 
-func (h *overlay) setContent(ctx context.Context, uri span.URI, content []byte) error {
-	return h.view().SetContent(ctx, uri, content)
+// route selects the view of the document from its build constraints, and
+// tells the user when the document is type-checked with another
+// configuration than the workspace one.
+func (h *overlay) route(ctx context.Context, uri span.URI, content []byte) {
+	configuration, changed := h.projectFor(uri).RouteFile(ctx, uri, content)
+	if !changed {
+		return
+	}
+
+	filename, _ := uri.Filename()
+	if configuration == "" {
+		h.conn.Notify(ctx, "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s is type-checked with the workspace configuration", filename)})
+		return
+	}
+	h.conn.Notify(ctx, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: fmt.Sprintf("%s is excluded by the workspace build configuration, it is type-checked with %s", filepath.Base(filename), configuration)})
 }
 
 type DiagnosticsStyleEnum string
@@ -164,18 +284,46 @@ const (
 	instantDiagnostics DiagnosticsStyleEnum = "instant"
 )
 
-func (h *overlay) diagnosetics(ctx context.Context, f source.File) {
-	reports, err := diagnostics(ctx, f)
-	if err == nil {
-		for filename, diagnostics := range reports {
-			fileURI := source.ToURI(filename)
-			params := &lsp.PublishDiagnosticsParams{
-				URI:         lsp.DocumentURI(fileURI),
-				Diagnostics: diagnostics,
-			}
+// documentVersions returns a snapshot of the versions of the opened
+// documents, indexed by filename.
+func (h *overlay) documentVersions() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	versions := make(map[string]int, len(h.versions))
+	for filename, version := range h.versions {
+		versions[filename] = version
+	}
+	return versions
+}
 
-			h.conn.Notify(ctx, "textDocument/publishDiagnostics", params)
+// snapshot returns the background context of the view of uri along with the
+// versions of the opened documents. Both are read together, so that the
+// context is cancelled as soon as any of the versions is outdated.
+func (h *overlay) snapshot(uri span.URI) (context.Context, map[string]int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	versions := make(map[string]int, len(h.versions))
+	for filename, version := range h.versions {
+		versions[filename] = version
+	}
+	return h.viewFor(uri).BackgroundContext(), versions
+}
+
+// publishDiagnostics sends the reports to the client. The diagnostics of an
+// opened document are tagged with the version found in versions, which must
+// be the one they were computed for.
+func (h *overlay) publishDiagnostics(ctx context.Context, reports map[string][]protocol.Diagnostic, versions map[string]int) {
+	for filename, diagnostics := range reports {
+		fileURI := source.ToURI(filename)
+		params := &protocol.PublishDiagnosticsParams{
+			URI:         h.synthetic.document(lsp.DocumentURI(fileURI)),
+			Version:     versions[filename],
+			Diagnostics: diagnostics,
 		}
+
+		h.conn.Notify(ctx, "textDocument/publishDiagnostics", params)
 	}
 }
 
@@ -230,7 +378,7 @@ func (h *overlay) applyChanges(ctx context.Context, params *lsp.DidChangeTextDoc
 		return nil, err
 	}
 
-	file, err := h.project.View().GetFile(ctx, sourceURI)
+	file, err := h.viewFor(sourceURI).GetFile(ctx, sourceURI)
 	if err != nil {
 		return nil, newJsonrpc2Errorf(jsonrpc2.CodeInternalError, "file not found")
 	}
@@ -6,16 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 
-	"golang.org/x/tools/imports"
-
 	lsp "github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
 	"github.com/sourcegraph/jsonrpc2"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
 	"github.com/saibing/bingo/langserver/internal/util"
 )
 
@@ -59,15 +59,33 @@ type LangHandler struct {
 	*HandlerShared
 	mu      sync.Mutex
 	init    *InitializeParams // set by "initialize" request
-	project *cache.Project
+	folders *workspaceFolders
 	cancel  *cancel
 	// DefaultConfig is the default values used for configuration. It is
-	// combined with InitializationOptions after initialize. This should be
-	// set by LangHandler creators. Please read config instead.
+	// combined with InitializationOptions after initialize, and with the
+	// settings of the client on workspace/didChangeConfiguration. This should
+	// be set by LangHandler creators. Please read getConfig instead.
 	DefaultConfig Config
-	// config is the language handler configuration. It is a combination of
-	// DefaultConfig and InitializationOptions.
-	config *Config // pointer so we panic if someone reads before we set it.
+
+	// clientCapabilities are the capabilities of the client missing from
+	// init, set by "initialize" request. capsMu guards them, the requests
+	// handled concurrently read them, see capabilities.
+	capsMu             sync.RWMutex
+	clientCapabilities protocol.ClientCapabilities
+
+	// progressTokens are the tokens of the progress reports.
+	progressTokens progressTokens
+
+	// watchFallback is set when the client failed to watch the files, the
+	// projects use the internal file watcher then.
+	watchFallback bool
+}
+
+// capabilities returns the capabilities of the client missing from init.
+func (h *LangHandler) capabilities() protocol.ClientCapabilities {
+	h.capsMu.RLock()
+	defer h.capsMu.RUnlock()
+	return h.clientCapabilities
 }
 
 // doInit clears all internal state in h.
@@ -79,28 +97,159 @@ func (h *LangHandler) doInit(ctx context.Context, conn *jsonrpc2.Conn, init *Ini
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	config := h.DefaultConfig.Apply(init.InitializationOptions)
-	h.config = &config
-	imports.LocalPrefix = h.config.GoimportsLocalPrefix
 	h.init = init
 	h.cancel = NewCancel()
 
-	rootPath := h.FilePath(init.Root())
-	buildFlags := []string{}
-	if len(h.config.BuildTags) > 0 {
-		buildFlags = append(buildFlags, "-tags", strings.Join(h.config.BuildTags, " "))
+	h.folders = newWorkspaceFolders()
+	h.overlay = newOverlay(conn, h.folders, configLayers{defaults: h.DefaultConfig, init: init.InitializationOptions})
+
+	folders := init.WorkspaceFolders
+	if len(folders) == 0 {
+		folders = []protocol.WorkspaceFolder{{URI: init.Root()}}
 	}
-	h.project = cache.NewProject(ctx, conn, rootPath, buildFlags)
-	h.overlay = newOverlay(conn, h.project, DiagnosticsStyleEnum(h.DefaultConfig.DiagnosticsStyle))
-	if err := h.project.Init(ctx, cache.CacheStyle(h.DefaultConfig.GlobalCacheStyle)); err != nil {
-		return err
+	for _, folder := range folders {
+		h.addFolder(conn, h.FilePath(folder.URI))
 	}
 	return nil
 }
 
-// handle implements jsonrpc2.Handler.
+// addFolder creates the project of the workspace folder rootPath, configured
+// by the configuration file of the folder. The project is loaded in the
+// background, which outlives the request adding the folder. Until then, the
+// opened files are type-checked on demand by the view. It is called with h.mu
+// held.
+func (h *LangHandler) addFolder(conn *jsonrpc2.Conn, rootPath string) {
+	folder := newWorkspaceFolder(util.LowerDriver(rootPath))
+	if _, err := folder.readConfigFile(folder.rootDir); err != nil {
+		h.notifyError(fmt.Sprintf("failed to read the configuration file: %s", err))
+	}
+	config := h.overlay.folderConfig(folder)
+	ctx, cancel := context.WithCancel(context.Background())
+	project := cache.NewProject(ctx, conn, rootPath, buildFlags(config))
+	if err := project.SetGoBinary(config.GoBinary); err != nil {
+		h.notifyError(fmt.Sprintf("failed to set the go command: %s", err))
+	}
+	project.SetEnv(goEnv(config))
+	if h.isRootFolder(folder) {
+		project.SetRootImportPath(h.init.RootImportPath)
+	}
+	project.SetDependencyCache(h.folders.deps)
+	project.SetIndexDir(config.IndexDirectory)
+	project.SetClientWatcher(clientWatchesFiles(h.capabilities()) && !h.watchFallback)
+	project.SetWalkOptions(walkOptions(config))
+	project.SetWatchMode(cache.WatchMode(config.WatchMode), config.PollInterval)
+	project.SetDependencyMode(cache.DependencyMode(config.DependencyMode))
+	project.SetMemoryLimit(uint64(config.MemoryLimit) << 20)
+	project.SetProgress(func(ctx context.Context, title string, cancellable bool) (context.Context, cache.Progress) {
+		progressCtx, cancel := ctx, context.CancelFunc(nil)
+		if cancellable {
+			progressCtx, cancel = context.WithCancel(ctx)
+		}
+		return progressCtx, h.newProgress(ctx, conn, title, 0, cancel)
+	})
+
+	project.SetModuleTags(h.overlay.moduleTagsFunc(folder))
+	project.SetConfigHandler(func(filename string) {
+		h.didChangeConfigFile(context.Background(), conn, filename)
+	})
+
+	folder.project, folder.cancel = project, cancel
+	if !h.folders.add(folder) {
+		cancel()
+		return
+	}
+	go h.loadProject(ctx, conn, project, cache.CacheStyle(config.GlobalCacheStyle))
+}
+
+// loadProject loads project into the global cache, and tells the client
+// when every project of the workspace is ready.
+func (h *LangHandler) loadProject(ctx context.Context, conn jsonrpc2.JSONRPC2, project *cache.Project, globalCacheStyle cache.CacheStyle) {
+	notifyStatus(ctx, conn, statusLoading, "")
+	if err := project.Init(ctx, globalCacheStyle); err != nil {
+		notifyStatus(ctx, conn, statusError, err.Error())
+		return
+	}
+	if !h.folders.loading() {
+		notifyStatus(ctx, conn, statusReady, "")
+	}
+}
+
+// handleDidChangeWorkspaceFolders creates the projects of the added folders
+// and drops the ones of the removed folders.
+func (h *LangHandler) handleDidChangeWorkspaceFolders(conn jsonrpc2.JSONRPC2, params protocol.DidChangeWorkspaceFoldersParams) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, folder := range params.Event.Removed {
+		h.folders.remove(util.LowerDriver(h.FilePath(folder.URI)))
+	}
+	for _, folder := range params.Event.Added {
+		h.addFolder(conn.(*jsonrpc2.Conn), h.FilePath(folder.URI))
+	}
+}
+
+// isRootFolder reports whether folder is the root of the workspace given by
+// the initialize request, which RootImportPath is the import path of.
+func (h *LangHandler) isRootFolder(folder *workspaceFolder) bool {
+	if h.init.RootURI == "" && h.init.RootPath == "" {
+		return false
+	}
+	return util.LowerDriver(h.FilePath(h.init.Root())) == folder.rootDir
+}
+
+// buildFlags returns the go build flags matching the config.
+func buildFlags(config *Config) []string {
+	flags := []string{}
+	if len(config.BuildTags) > 0 {
+		flags = append(flags, "-tags", strings.Join(config.BuildTags, " "))
+	}
+	return flags
+}
+
+// goEnv returns the environment variables of the config, in the KEY=value
+// form.
+func goEnv(config *Config) []string {
+	var env []string
+	for key, value := range config.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// walkOptions returns the walked directories of the workspace matching the
+// config.
+func walkOptions(config *Config) cache.WalkOptions {
+	return cache.WalkOptions{
+		Include:     config.IncludePatterns,
+		Exclude:     config.ExcludePatterns,
+		MaxDepth:    config.MaxDepth,
+		IgnoreFiles: config.UseIgnoreFiles,
+	}
+}
+
+// handle implements jsonrpc2.Handler. The requests on the documents which
+// aren't files are served for their synthetic files, see toSyntheticFile.
 func (h *LangHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
-	return h.Handle(ctx, conn, req)
+	if h.overlay == nil {
+		return h.Handle(ctx, conn, req)
+	}
+
+	req, document, err := h.toSyntheticFile(req)
+	if err != nil {
+		return nil, err
+	}
+	result, err = h.Handle(ctx, conn, req)
+	if document == "" {
+		return result, err
+	}
+	if req.Method == "textDocument/didClose" {
+		h.overlay.synthetic.close(document)
+	}
+	if err != nil || result == nil {
+		return result, err
+	}
+	return h.overlay.synthetic.fromSyntheticFiles(result)
 }
 
 // Handle creates a response for a JSONRPC2 LSP request. Note: LSP has strict
@@ -115,6 +264,21 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		}
 	}()
 
+	// The loads run in the background, their cancellation doesn't wait for
+	// the requests holding the lock.
+	if req.Method == "window/workDoneProgress/cancel" {
+		// notification, don't send back results/errors
+		if req.Params == nil {
+			return nil, nil
+		}
+		var params protocol.WorkDoneProgressCancelParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, nil
+		}
+		h.progressTokens.cancel(params.Token)
+		return nil, nil
+	}
+
 	h.mu.Lock()
 	cancelManager := h.cancel
 	if req.Method != "initialize" && h.init == nil {
@@ -122,6 +286,9 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		return nil, errors.New("server must be initialized")
 	}
 	h.mu.Unlock()
+	if h.folders != nil && len(h.folders.projects()) == 0 && needsFolder(req.Method) {
+		return nil, errors.New("no workspace folder is opened")
+	}
 	if err := h.CheckReady(); err != nil {
 		if req.Method == "exit" {
 			err = nil
@@ -148,6 +315,15 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
+		var caps struct {
+			Capabilities protocol.ClientCapabilities `json:"capabilities"`
+		}
+		if err := json.Unmarshal(*req.Params, &caps); err != nil {
+			return nil, err
+		}
+		h.capsMu.Lock()
+		h.clientCapabilities = caps.Capabilities
+		h.capsMu.Unlock()
 
 		// HACK: RootPath is not a URI, but historically we treated it
 		// as such. Convert it to a file URI
@@ -162,8 +338,11 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		kind := lsp.TDSKIncremental
 		completionOp := &lsp.CompletionOptions{TriggerCharacters: []string{"."}}
 		signatureHelpProvider := &lsp.SignatureHelpOptions{TriggerCharacters: []string{"(", ","}}
-		return lsp.InitializeResult{
-			Capabilities: lsp.ServerCapabilities{
+		var workspace protocol.WorkspaceServerCapabilities
+		workspace.WorkspaceFolders.Supported = true
+		workspace.WorkspaceFolders.ChangeNotifications = true
+		return protocol.InitializeResult{
+			Capabilities: protocol.ServerCapabilities{ServerCapabilities: lsp.ServerCapabilities{
 				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
 					Kind:    &kind,
 					Options: &lsp.TextDocumentSyncOptions{OpenClose: true},
@@ -184,11 +363,22 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 				XDefinitionProvider:             true,
 				XWorkspaceSymbolByProperties:    true,
 				SignatureHelpProvider:           signatureHelpProvider,
-			},
+				ExecuteCommandProvider: &lsp.ExecuteCommandOptions{
+					Commands: []string{checkWorkspaceCommand, cacheStatsCommand},
+				},
+			}, Workspace: &workspace},
 		}, nil
 
 	case "initialized":
-		// A notification that the client is ready to receive requests. Ignore
+		// A notification that the client is ready to receive requests.
+		h.progressTokens.setInitialized()
+		h.registerWatchedFiles(ctx, conn)
+		h.registerConfiguration(ctx, conn)
+		if h.capabilities().Workspace.Configuration {
+			if err := h.fetchConfiguration(ctx, conn); err != nil {
+				log.Printf("failed to fetch the configuration: %s", err)
+			}
+		}
 		return nil, nil
 
 	case "shutdown":
@@ -338,7 +528,12 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 		if err := json.Unmarshal(*req.Params, &params); err != nil {
 			return nil, err
 		}
-		return h.handleWorkspaceSymbol(ctx, conn, req, params)
+		loading := h.folders.loading()
+		symbols, err := h.handleWorkspaceSymbol(ctx, conn, req, params)
+		if err != nil || !loading {
+			return symbols, err
+		}
+		return partialSymbols(symbols), nil
 
 	case "workspace/xreferences":
 		if req.Params == nil {
@@ -371,6 +566,45 @@ func (h *LangHandler) Handle(ctx context.Context, conn jsonrpc2.JSONRPC2, req *j
 
 		return h.handleCodeAction(ctx, conn, req, params)
 
+	case "workspace/didChangeWorkspaceFolders":
+		// notification, don't send back results/errors
+		if req.Params == nil {
+			return nil, nil
+		}
+		var params protocol.DidChangeWorkspaceFoldersParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, nil
+		}
+		h.handleDidChangeWorkspaceFolders(conn, params)
+		return nil, nil
+
+	case "workspace/didChangeConfiguration":
+		// notification, don't send back results/errors
+		if req.Params == nil {
+			return nil, nil
+		}
+		var params protocol.DidChangeConfigurationParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, nil
+		}
+		if err := h.handleDidChangeConfiguration(ctx, conn, params); err != nil {
+			log.Printf("failed to apply the configuration: %s", err)
+		}
+		return nil, nil
+
+	case "workspace/didChangeWatchedFiles":
+		return nil, h.handleDidChangeWatchedFiles(ctx, conn, req)
+
+	case "workspace/executeCommand":
+		if req.Params == nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		}
+		var params lsp.ExecuteCommandParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, err
+		}
+		return h.handleExecuteCommand(ctx, conn, req, params)
+
 	default:
 		if isFileSystemRequest(req.Method) {
 			err := h.handleFileSystemRequest(ctx, req)
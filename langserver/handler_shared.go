@@ -7,6 +7,7 @@ import (
 
 	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
 	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 )
@@ -39,8 +40,64 @@ func (h *HandlerShared) notifyLog(message string) {
 	_ = h.overlay.conn.Notify(context.Background(), "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
 }
 
-func (h *HandlerShared) View() source.View {
-	return h.overlay.view()
+// projectFor returns the project of the workspace folder of the document
+// uri.
+func (h *HandlerShared) projectFor(uri lsp.DocumentURI) *cache.Project {
+	return h.overlay.folders.projectFor(span.FromDocumentURI(uri))
+}
+
+// projectOf returns the project of the workspace folder which loaded pkg.
+func (h *HandlerShared) projectOf(pkg source.Package) *cache.Project {
+	filenames := pkg.GetFilenames()
+	if len(filenames) == 0 {
+		return h.projects()[0]
+	}
+	return h.overlay.folders.projectFor(span.FileURI(filenames[0]))
+}
+
+// projects returns the projects of the workspace folders.
+func (h *HandlerShared) projects() []*cache.Project {
+	return h.overlay.folders.projects()
+}
+
+// search walks the packages of the projects of the workspace folders. A
+// dependency shared by several projects is walked in the first one only.
+func (h *HandlerShared) search(walkFunc source.WalkFunc) error {
+	walked := make(map[string]bool)
+	for _, project := range h.projects() {
+		current := make(map[string]bool)
+		err := project.Search(func(pkg source.Package) error {
+			key := pkg.GetPkgPath() + " " + strings.Join(pkg.GetFilenames(), " ")
+			if walked[key] {
+				return nil
+			}
+			current[key] = true
+			return walkFunc(pkg)
+		})
+		if err != nil {
+			return err
+		}
+		for key := range current {
+			walked[key] = true
+		}
+	}
+	return nil
+}
+
+// getConfig returns the active configuration.
+func (h *HandlerShared) getConfig() *Config {
+	return h.overlay.getConfig()
+}
+
+// configFor returns the configuration of the document uri, see
+// overlay.configFor.
+func (h *HandlerShared) configFor(uri lsp.DocumentURI) *Config {
+	return h.overlay.configFor(span.FromDocumentURI(uri))
+}
+
+// viewFor returns the view type-checking the document uri.
+func (h *HandlerShared) viewFor(uri lsp.DocumentURI) source.View {
+	return h.overlay.viewFor(span.FromDocumentURI(uri))
 }
 
 func (h *HandlerShared) getFindPackageFunc() cache.FindPackageFunc {
@@ -106,7 +106,7 @@ func (h *LangHandler) hoverIdent(pkg source.Package, pathNodes []ast.Node, ident
 	isBuiltIn, builtInObject := o != nil && !o.Pos().IsValid(), o
 	if isBuiltIn {
 		// Only builtins have invalid position, and don't have useful info.
-		pkg = h.project.GetBuiltinPackage()
+		pkg = h.projectOf(pkg).GetBuiltinPackage()
 		if pkg == nil {
 			return nil, nil
 		}
@@ -198,10 +198,14 @@ func packageStatementName(fset *token.FileSet, files []*ast.File, node *ast.Iden
 
 // maybeAddComments appends the specified comments converted to Markdown godoc
 // form to the specified contents slice, if the comments string is not empty.
+// The deprecation notice of the comments, if any, is put first.
 func maybeAddComments(comments string, contents []lsp.MarkedString) []lsp.MarkedString {
 	if comments == "" {
 		return contents
 	}
+	if notice := source.Deprecation(comments); notice != "" {
+		contents = append([]lsp.MarkedString{lsp.RawMarkedString("**Deprecated:** " + notice)}, contents...)
+	}
 	var b bytes.Buffer
 	doc.ToMarkdown(&b, comments, nil)
 	return append(contents, lsp.RawMarkedString(b.String()))
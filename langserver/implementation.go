@@ -31,7 +31,7 @@ func (h *LangHandler) handleTextDocumentImplementation(ctx context.Context, conn
 	pathNodes, _ := source.GetPathNodes(pkg, pkg.GetFileSet(), pos, pos)
 	pathNodes, action := findInterestingNode(pkg, pathNodes)
 
-	return implements(h.project, pkg, pathNodes, action)
+	return implements(h.projectFor(params.TextDocument.URI), pkg, pathNodes, action)
 }
 
 // Adapted from golang.org/x/tools/cmd/guru (Copyright (c) 2013 The Go Authors). All rights
@@ -1,6 +1,9 @@
 package langserver
 
-import lsp "github.com/sourcegraph/go-lsp"
+import (
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+)
 
 // This file contains Go-specific extensions to LSP types.
 //
@@ -19,11 +22,43 @@ type InitializationOptions struct {
 	// Defaults to false if not specified.
 	DiagnosticsStyle *string `json:"diagnosticsStyle"`
 
+	// DiagnosticsDelay is an optional version of Config.DiagnosticsDelay,
+	// in milliseconds
+	DiagnosticsDelay *int `json:"diagnosticsDelay"`
+
 	// EnableGlobalCache enable global cache when hover, reference, definition. Can be overridden by InitializationOptions.
 	//
 	// Defaults to false if not specified
 	GlobalCacheStyle *string `json:"globalCacheStyle"`
 
+	// IndexDirectory is an optional version of Config.IndexDirectory
+	IndexDirectory *string `json:"indexDirectory"`
+
+	// IncludePatterns is an optional version of Config.IncludePatterns
+	IncludePatterns []string `json:"includePatterns"`
+
+	// ExcludePatterns is an optional version of Config.ExcludePatterns
+	ExcludePatterns []string `json:"excludePatterns"`
+
+	// MaxDepth is an optional version of Config.MaxDepth
+	MaxDepth *int `json:"maxDepth"`
+
+	// UseIgnoreFiles is an optional version of Config.UseIgnoreFiles
+	UseIgnoreFiles *bool `json:"useIgnoreFiles"`
+
+	// DependencyMode is an optional version of Config.DependencyMode
+	DependencyMode *string `json:"dependencyMode"`
+
+	// MemoryLimit is an optional version of Config.MemoryLimit
+	MemoryLimit *int `json:"memoryLimit"`
+
+	// WatchMode is an optional version of Config.WatchMode
+	WatchMode *string `json:"watchMode"`
+
+	// PollInterval is an optional version of Config.PollInterval, in
+	// milliseconds
+	PollInterval *int `json:"pollInterval"`
+
 	// FormatStyle format style
 	//
 	// Defaults to "gofmt" if not specified
@@ -40,6 +75,34 @@ type InitializationOptions struct {
 
 	// BuildTags is an optional version of Config.BuildTags
 	BuildTags []string `json:"buildTags"`
+
+	// BuildConfigurations is an optional version of
+	// Config.BuildConfigurations
+	BuildConfigurations []BuildConfiguration `json:"buildConfigurations"`
+
+	// ReverseDependencyDepth is an optional version of
+	// Config.ReverseDependencyDepth
+	ReverseDependencyDepth *int `json:"reverseDependencyDepth"`
+
+	// ReverseDependencyBudget is an optional version of
+	// Config.ReverseDependencyBudget, in milliseconds
+	ReverseDependencyBudget *int `json:"reverseDependencyBudget"`
+
+	// DeprecatedSeverity is an optional version of Config.DeprecatedSeverity
+	DeprecatedSeverity *string `json:"deprecatedSeverity"`
+
+	// Analyses is an optional version of Config.Analyses
+	Analyses []string `json:"analyses"`
+
+	// Env is an optional version of Config.Env. Its variables are added to
+	// the ones of the lower precedence sources.
+	Env map[string]string `json:"env"`
+
+	// GoBinary is an optional version of Config.GoBinary
+	GoBinary *string `json:"goBinary"`
+
+	// SyntheticDirectory is an optional version of Config.SyntheticDirectory
+	SyntheticDirectory *string `json:"syntheticDirectory"`
 }
 
 type InitializeParams struct {
@@ -51,6 +114,12 @@ type InitializeParams struct {
 	// RootImportPath is the root Go import path for this
 	// workspace. For example,
 	// "golang.org/x/tools" is the root import
-	// path for "github.com/golang/tools".
+	// path for "github.com/golang/tools". In GOPATH mode, the
+	// repository of the root folder is found from its VCS
+	// directories when it is empty.
 	RootImportPath string
+
+	// WorkspaceFolders are the folders of the workspace, each one is loaded
+	// as a project. The root is the only folder when it is empty.
+	WorkspaceFolders []protocol.WorkspaceFolder `json:"workspaceFolders,omitempty"`
 }
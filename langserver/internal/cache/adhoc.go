@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/packages"
+)
+
+// commandLinePackage is the package path given by the go command to the
+// package of the .go files listed on its command line.
+const commandLinePackage = "command-line-arguments"
+
+// isAdhocDir reports whether the packages of dir are neither in GOPATH nor in
+// a module for the go commands run with env, such as a scratch file or a
+// script directory. Their files are loaded as ad-hoc packages, see adhocFiles.
+func isAdhocDir(dir string, env []string) bool {
+	dir = util.LowerDriver(filepath.ToSlash(dir))
+	if strings.HasPrefix(dir, goroot) || isFileInsideGomod(dir) {
+		return false
+	}
+
+	mode := getenv(env, go111module)
+	if mode != "on" && gopathImportPath(dir) != "" {
+		return false
+	}
+	return mode == "off" || findGoMod(dir) == ""
+}
+
+// findGoMod returns the go.mod file of the module of dir, found in dir or in
+// its parents, or "".
+func findGoMod(dir string) string {
+	dir = filepath.FromSlash(dir)
+	for {
+		filename := filepath.Join(dir, gomod)
+		if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
+			return filename
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isTestFile reports whether filename is a test file.
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(filename, "_test"+goext)
+}
+
+// adhocFiles returns the files of the ad-hoc package of filename: the .go
+// files of its directory in the same package and matching the build
+// configuration of the view, the test files only when filename is one. A file
+// excluded by the "ignore" build tag, such as a generator run by go run, is a
+// program of its own. It is called with v.mu held.
+func (v *View) adhocFiles(filename string) []string {
+	fset := token.NewFileSet()
+	packageName := func(filename string) string {
+		src, ok := v.Config.Overlay[filename]
+		if !ok {
+			var err error
+			if src, err = ioutil.ReadFile(filename); err != nil {
+				return ""
+			}
+		}
+		f, _ := parser.ParseFile(fset, filename, src, parser.PackageClauseOnly)
+		if f == nil || f.Name == nil {
+			return ""
+		}
+		return f.Name.Name
+	}
+
+	// The view of an ignored file builds with the "ignore" tag, which must
+	// not gather the other ignored files.
+	c := configConfiguration(v.Config.Env, v.Config.BuildFlags)
+	var tags []string
+	for _, tag := range c.Tags {
+		if tag != "ignore" {
+			tags = append(tags, tag)
+		}
+	}
+	c.Tags = tags
+
+	files := []string{filename}
+	name := packageName(filename)
+	if name == "" || !c.matchFile(filename, v.Config.Overlay[filename]) {
+		return files
+	}
+	infos, err := ioutil.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return files
+	}
+	for _, fi := range infos {
+		other := filepath.Join(filepath.Dir(filename), fi.Name())
+		if fi.IsDir() || filepath.Ext(other) != goext || other == filename {
+			continue
+		}
+		if isTestFile(other) && !isTestFile(filename) {
+			continue
+		}
+		if !c.matchFile(other, v.Config.Overlay[other]) {
+			continue
+		}
+		if packageName(other) == name {
+			files = append(files, other)
+		}
+	}
+	sort.Strings(files[1:])
+	return files
+}
+
+// adhocPackages returns the ad-hoc packages among pkgs, loaded from files of
+// dir. Their package path becomes dir, so that the ad-hoc packages of several
+// directories don't clash.
+func adhocPackages(pkgs []*packages.Package, dir string) []*packages.Package {
+	var result []*packages.Package
+	for _, pkg := range pkgs {
+		if pkg.PkgPath != commandLinePackage {
+			// The test main package of a test file.
+			continue
+		}
+		pkg.PkgPath = filepath.ToSlash(dir)
+		pkg.ID = strings.Replace(pkg.ID, commandLinePackage, pkg.PkgPath, -1)
+		result = append(result, pkg)
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAdhocFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-adhoc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"a.go":        "package main\n",
+		"b.go":        "// Package main.\npackage main\n",
+		"c.go":        "package other\n",
+		"a_test.go":   "package main\n",
+		"x_test.go":   "package main_test\n",
+		"notes.txt":   "package main\n",
+		"mod/go.mod":  "module example.com/mod\n",
+		"mod/m/m.go":  "package m\n",
+		"script/s.go": "package main\n",
+		"w_plan9.go":  "package main\n",
+		"gen.go":      "// +build ignore\n\npackage main\n",
+		"gen2.go":     "// +build ignore\n\npackage main\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := []string{"GO111MODULE=auto"}
+	if !isAdhocDir(root, env) || !isAdhocDir(filepath.Join(root, "script"), env) {
+		t.Errorf("directories outside of GOPATH and of the modules are not ad-hoc")
+	}
+	if isAdhocDir(filepath.Join(root, "mod", "m"), env) {
+		t.Errorf("package of a module is ad-hoc")
+	}
+	if !isAdhocDir(filepath.Join(root, "mod", "m"), []string{"GO111MODULE=off"}) {
+		t.Errorf("package outside of GOPATH is not ad-hoc with GO111MODULE=off")
+	}
+
+	v := NewView(&packages.Config{Overlay: map[string][]byte{filepath.Join(root, "c.go"): []byte("package main\n")}})
+	path := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(root, name))
+		}
+		return paths
+	}
+	// The view of the ignored files builds with the "ignore" tag.
+	ignore := NewView(&packages.Config{BuildFlags: []string{"-tags=ignore"}})
+	tests := []struct {
+		v        *View
+		filename string
+		want     []string
+	}{
+		{v, "b.go", path("b.go", "a.go", "c.go")},
+		{v, "a_test.go", path("a_test.go", "a.go", "b.go", "c.go")},
+		{v, "x_test.go", path("x_test.go")},
+		{ignore, "gen.go", path("gen.go")},
+		{ignore, "b.go", path("b.go", "a.go")},
+	}
+	if runtime.GOOS == "plan9" {
+		t.Skip("the file excluded by its name is built on plan9")
+	}
+	for _, test := range tests {
+		if got := test.v.adhocFiles(filepath.Join(root, test.filename)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("adhocFiles(%s) = %v, want %v", test.filename, got, test.want)
+		}
+	}
+}
+
+func TestAdhocPackages(t *testing.T) {
+	pkgs := []*packages.Package{
+		{ID: commandLinePackage, PkgPath: commandLinePackage},
+		{ID: commandLinePackage + " [" + commandLinePackage + ".test]", PkgPath: commandLinePackage},
+		{ID: commandLinePackage + ".test", PkgPath: commandLinePackage + ".test"},
+	}
+	got := adhocPackages(pkgs, "/src/script")
+	if len(got) != 2 {
+		t.Fatalf("got %d packages, want the package and its test variant", len(got))
+	}
+	if got[0].ID != "/src/script" || got[0].PkgPath != "/src/script" {
+		t.Errorf("got package %s with path %s, want /src/script", got[0].ID, got[0].PkgPath)
+	}
+	if want := "/src/script [/src/script.test]"; got[1].ID != want {
+		t.Errorf("got test variant %s, want %s", got[1].ID, want)
+	}
+}
+
+func TestAdhocIgnoredScripts(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-adhoc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	script := "// +build ignore\n\npackage main\n\nfunc main() {}\n"
+	writeTestFiles(t, root, map[string]string{"gen.go": script, "gen2.go": script})
+
+	// The scripts run by go run don't redeclare the main of each other.
+	ctx := context.Background()
+	v := NewView(&packages.Config{
+		Context:    ctx,
+		Dir:        root,
+		Env:        append(os.Environ(), "GO111MODULE=auto"),
+		BuildFlags: []string{"-tags=ignore"},
+		Fset:       token.NewFileSet(),
+		Overlay:    make(map[string][]byte),
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	})
+	v.adhocDir = isAdhocDir
+	f, err := v.GetFile(ctx, span.FileURI(filepath.Join(root, "gen.go")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil {
+		t.Fatal("no package for gen.go")
+	}
+	if errs := pkg.GetErrors(); len(errs) != 0 {
+		t.Errorf("got errors %v for the script", errs)
+	}
+}
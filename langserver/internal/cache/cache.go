@@ -3,6 +3,7 @@ package cache
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -60,6 +62,12 @@ func getPackageModTime(pkg *Package) time.Time {
 
 // PackageCache package cache
 type GlobalCache struct {
+	// evictions and reloads count the packages evicted from memory and
+	// loaded again, see CacheStats. They come first for the alignment of
+	// the atomic operations.
+	evictions int64
+	reloads   int64
+
 	mu      sync.RWMutex
 	idMap   id2Package
 	pathMap path2Package
@@ -273,6 +281,97 @@ func (c *GlobalCache) walk(idList []string, walkFunc source.WalkFunc) error {
 	return nil
 }
 
+// Importers returns the packages which import the package pkgPath, directly
+// or indirectly through at most depth levels of imports. The nearest importers
+// come first.
+func (c *GlobalCache) Importers(pkgPath string, depth int) []*Package {
+	if c == nil || depth <= 0 {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	importers := map[string][]*Package{}
+	for _, p := range c.idMap {
+		for importPath := range p.pkg.imports {
+			importers[importPath] = append(importers[importPath], p.pkg)
+		}
+	}
+
+	var result []*Package
+	seenID := map[string]bool{}
+	seenPath := map[string]bool{pkgPath: true}
+	current := []string{pkgPath}
+	for level := 0; level < depth && len(current) > 0; level++ {
+		var next []string
+		for _, path := range current {
+			for _, pkg := range importers[path] {
+				if seenID[pkg.id] || pkg.pkgPath == pkgPath {
+					continue
+				}
+				seenID[pkg.id] = true
+				result = append(result, pkg)
+				if !seenPath[pkg.pkgPath] {
+					seenPath[pkg.pkgPath] = true
+					next = append(next, pkg.pkgPath)
+				}
+			}
+		}
+		current = next
+	}
+
+	return result
+}
+
+// PackagesIn returns the packages whose files are in one of the directories
+// dirs, or below one of the directories trees.
+func (c *GlobalCache) PackagesIn(dirs map[string]bool, trees []string) []*Package {
+	if c == nil {
+		return nil
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	var result []*Package
+	for _, p := range c.idMap {
+		if len(p.pkg.files) == 0 {
+			continue
+		}
+
+		dir := packageDir(p.pkg.files[0])
+		if dirs[dir] || underTrees(dir, trees) {
+			result = append(result, p.pkg)
+		}
+	}
+
+	return result
+}
+
+func packageDir(filename string) string {
+	return util.LowerDriver(filepath.ToSlash(filepath.Dir(filename)))
+}
+
+// Replace removes the packages idList from the cache and adds pkgs in their
+// place, at once. The imports of pkgs which are still cached are shared.
+func (c *GlobalCache) Replace(idList []string, pkgs []*packages.Package) {
+	if c == nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, id := range idList {
+		c.delete(id)
+	}
+
+	for _, pkg := range pkgs {
+		c.recusiveAdd(pkg, nil)
+	}
+}
+
 func (c *GlobalCache) Add(pkg *packages.Package) {
 	if c == nil {
 		return
@@ -317,5 +416,7 @@ func create(pkg *packages.Package) *Package {
 		typesInfo: pkg.TypesInfo,
 		fset:      pkg.Fset,
 		imports:   make(map[string]*Package),
+		lastUsed:  new(int64),
+		analyses:  make(map[*analysis.Analyzer]*analysisEntry),
 	}
 }
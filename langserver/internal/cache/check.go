@@ -97,10 +97,25 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 		return nil, err
 	}
 	if v.reparseImports(ctx, f, filename) {
+		if isSyntheticFile(filename) {
+			return nil, v.syntheticMetadata(ctx, f, filename)
+		}
 		cfg := v.Config
 		cfg.Mode = packages.LoadImports
 		cfg.Dir = filepath.Dir(filename)
-		pkgs, err := packages.Load(&cfg, fmt.Sprintf("file=%s", filename))
+		if v.dirFlags != nil {
+			cfg.BuildFlags = v.dirFlags(cfg.Dir, cfg.BuildFlags)
+		}
+		patterns := []string{fmt.Sprintf("file=%s", filename)}
+		adhoc := v.adhocDir != nil && v.adhocDir(cfg.Dir, cfg.Env)
+		if adhoc {
+			patterns = v.adhocFiles(filename)
+			cfg.Tests = isTestFile(filename)
+		}
+		pkgs, err := packages.Load(&cfg, patterns...)
+		if adhoc {
+			pkgs = adhocPackages(pkgs, cfg.Dir)
+		}
 		if len(pkgs) == 0 {
 			if err == nil {
 				err = fmt.Errorf("no packages found for %s", filename)
@@ -119,6 +134,51 @@ func (v *View) checkMetadata(ctx context.Context, f *File) ([]packages.Error, er
 	return nil, nil
 }
 
+// checkPackage type-checks the package pkgPath of filename again, loading its
+// metadata if the view doesn't know it yet. Unlike GetFile, it doesn't add the
+// files of the package to the files of the view.
+func (v *View) checkPackage(ctx context.Context, pkgPath, filename string) (*Package, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	if err := v.applyContentChanges(ctx); err != nil {
+		return nil, err
+	}
+	if _, ok := v.mcache.packages[pkgPath]; !ok {
+		cfg := v.Config
+		cfg.Mode = packages.LoadImports
+		cfg.Dir = filepath.Dir(filename)
+		if v.dirFlags != nil {
+			cfg.BuildFlags = v.dirFlags(cfg.Dir, cfg.BuildFlags)
+		}
+		pkgs, err := packages.Load(&cfg, fmt.Sprintf("file=%s", filename))
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range pkgs {
+			if len(pkg.Errors) > 0 {
+				return nil, fmt.Errorf("package %s has errors, skipping type-checking", pkg.PkgPath)
+			}
+			v.link(pkg.PkgPath, pkg, nil)
+		}
+	}
+
+	imp := &importer{
+		view:     v,
+		circular: make(map[string]struct{}),
+	}
+	pkg, err := imp.typeCheck(pkgPath, false)
+	if pkg == nil || pkg.GetTypes() == nil {
+		if err == nil {
+			err = fmt.Errorf("no package found for %s", pkgPath)
+		}
+		return nil, err
+	}
+	return pkg, nil
+}
+
 // reparseImports reparses a file's import declarations to determine if they
 // have changed.
 func (v *View) reparseImports(ctx context.Context, f *File, filename string) bool {
@@ -247,6 +307,7 @@ func (imp *importer) typeCheck(pkgPath string, isImport bool) (*Package, error)
 			Selections: make(map[*ast.SelectorExpr]*types.Selection),
 			Scopes:     make(map[ast.Node]*types.Scope),
 		},
+		lastUsed: new(int64),
 		analyses: make(map[*analysis.Analyzer]*analysisEntry),
 	}
 
@@ -308,7 +369,25 @@ func (imp *importer) cloneFromCache(pkg *Package) bool {
 		return false
 	}
 
-	*pkg = *clone.Package()
+	c := clone.Package()
+	c.detailsMu.Lock()
+	defer c.detailsMu.Unlock()
+	*pkg = Package{
+		id:        c.id,
+		pkgPath:   c.pkgPath,
+		name:      c.name,
+		files:     c.files,
+		syntax:    c.syntax,
+		errors:    c.errors,
+		imports:   c.imports,
+		types:     c.types,
+		typesInfo: c.typesInfo,
+		fset:      c.fset,
+		source:    c.source,
+		summary:   c.summary,
+		lastUsed:  c.lastUsed,
+		analyses:  make(map[*analysis.Analyzer]*analysisEntry),
+	}
 	return true
 }
 
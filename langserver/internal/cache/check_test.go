@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestCheckPackage(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nfunc F() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar _ = a.F\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	v := NewView(&packages.Config{
+		Context: ctx,
+		Dir:     root,
+		Env:     append(os.Environ(), "GO111MODULE=on"),
+		Fset:    token.NewFileSet(),
+		Overlay: make(map[string][]byte),
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	})
+	aURI := span.FileURI(filepath.Join(root, "a", "a.go"))
+	bFilename := filepath.Join(root, "b", "b.go")
+	f, err := v.GetFile(ctx, aURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.GetPackage(ctx) == nil {
+		t.Fatal("no package for a.go")
+	}
+
+	pkg, err := v.checkPackage(ctx, "example.com/m/b", bFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := pkg.GetErrors(); len(errs) != 0 {
+		t.Fatalf("got errors %v before the edit", errs)
+	}
+
+	// The importer is type-checked against the edited content.
+	if err := v.SetContent(ctx, aURI, []byte("package a\n\nfunc G() {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err = v.checkPackage(ctx, "example.com/m/b", bFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := pkg.GetErrors(); len(errs) != 1 || !strings.Contains(errs[0].Msg, "F") {
+		t.Errorf("got errors %v, want the undeclared a.F", errs)
+	}
+
+	if _, ok := v.files[span.FileURI(bFilename)]; ok {
+		t.Errorf("the file of the importer was added to the view")
+	}
+}
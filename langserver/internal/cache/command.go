@@ -4,17 +4,115 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
+// SetEnv sets the environment variables, in the KEY=value form, which
+// override the environment of the server for the go commands run for the
+// project and its packages.Load calls, eg. GOFLAGS, GOPROXY or CGO_ENABLED.
+func (p *Project) SetEnv(env []string) {
+	if len(env) == 0 {
+		return
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	v.Config.Env = p.configEnv(env)
+	v.mu.Unlock()
+}
+
+// configEnv returns the environment of the go commands overridden by env and
+// by the packages driver of the go command of the project, nil for the
+// environment of the server.
+func (p *Project) configEnv(env []string) []string {
+	env = append(append([]string{}, env...), p.driverEnv...)
+	if len(env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), env...)
+}
+
+// Env returns the environment of the go commands run for the project.
+func (p *Project) Env() []string {
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.Config.Env == nil {
+		return os.Environ()
+	}
+	return append([]string{}, v.Config.Env...)
+}
+
+// getenv returns the value of the environment variable key of the go commands
+// run for the project.
+func (p *Project) getenv(key string) string {
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return getenv(v.Config.Env, key)
+}
+
+// getenv returns the value of the environment variable key in env, the last
+// one set, else in the environment of the process.
+func getenv(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], key+"=") {
+			return strings.TrimPrefix(env[i], key+"=")
+		}
+	}
+	return os.Getenv(key)
+}
+
+// SetGoBinary sets the go command run for the project, in place of the go
+// command of the PATH. go/packages always runs the go command of the PATH of
+// the server, so the packages of the project are listed by the server run as
+// a packages driver, see RunPackagesDriver, with goBinary first in its PATH.
+// It must be called before SetEnv.
+func (p *Project) SetGoBinary(goBinary string) error {
+	if goBinary == "" {
+		return nil
+	}
+	goBinary, err := filepath.Abs(goBinary)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(goBinary); err != nil {
+		return err
+	}
+	env, err := driverEnv(goBinary)
+	if err != nil {
+		return err
+	}
+	p.goBinary = goBinary
+	p.driverEnv = env
+
+	v := p.getView()
+	v.mu.Lock()
+	v.Config.Env = p.configEnv(nil)
+	v.mu.Unlock()
+	return nil
+}
+
 // invokeGo returns the stdout of a go command invocation.
-func invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, error) {
+func (p *Project) invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
 
-	cmd := exec.CommandContext(ctx, "go", args...)
+	goBinary := p.goBinary
+	if goBinary == "" {
+		goBinary = "go"
+	}
+	v := p.getView()
+	v.mu.Lock()
+	env := v.Config.Env
+	v.mu.Unlock()
+
+	cmd := exec.CommandContext(ctx, goBinary, args...)
 	cmd.Dir = dir
+	cmd.Env = env
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
@@ -23,7 +121,7 @@ func invokeGo(ctx context.Context, dir string, args ...string) (*bytes.Buffer, e
 			// Catastrophic error:
 			// - executable not found
 			// - context cancellation
-			return nil, fmt.Errorf("couldn't exec 'go %v': %s %T", args, err, err)
+			return nil, fmt.Errorf("couldn't exec '%s %v': %s %T", goBinary, args, err, err)
 		}
 
 		// Old go version?
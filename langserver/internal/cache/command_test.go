@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary is the packages driver of the projects whose go
+	// command is set, see TestGoBinary.
+	if IsPackagesDriver() {
+		if err := RunPackagesDriver(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestProjectEnv(t *testing.T) {
+	p := NewProject(context.Background(), nil, "/ws", nil)
+	if got := p.getenv("BINGO_TEST_ENV"); got != "" {
+		t.Fatalf("BINGO_TEST_ENV = %q without overrides", got)
+	}
+
+	p.SetEnv([]string{"BINGO_TEST_ENV=a", "GOFLAGS=-mod=mod", "BINGO_TEST_ENV=b"})
+	if got := p.getenv("BINGO_TEST_ENV"); got != "b" {
+		t.Errorf("BINGO_TEST_ENV = %q, want the last value b", got)
+	}
+
+	stdout, err := p.invokeGo(context.Background(), ".", "env", "GOFLAGS")
+	if err != nil {
+		t.Skipf("go command unavailable: %v", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "-mod=mod" {
+		t.Errorf("go env GOFLAGS = %q, want -mod=mod", got)
+	}
+}
+
+func TestGoBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the go command is wrapped by a shell script")
+	}
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("go command unavailable: %v", err)
+	}
+
+	root, err := ioutil.TempDir("", "bingo-gobinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// The go command of the project logs its runs, and is named otherwise
+	// than go.
+	log := filepath.Join(root, "go.log")
+	wrapper := filepath.Join(root, "bin", "go1.x")
+	writeTestFiles(t, root, map[string]string{
+		"bin/go1.x": fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\nexec %s \"$@\"\n", log, goBinary),
+		"m/go.mod":  "module example.com/m\n",
+		"m/a/a.go":  "package a\n\nimport \"fmt\"\n\nvar _ = fmt.Println\n",
+	})
+	if err := os.Chmod(wrapper, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	linkDirs := func() []string {
+		dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), "bingo-go[0-9]*"))
+		return dirs
+	}
+	path, links := os.Getenv("PATH"), len(linkDirs())
+	p := NewProject(context.Background(), testConn{}, filepath.Join(root, "m"), nil)
+	if err := p.SetGoBinary(wrapper); err != nil {
+		t.Fatal(err)
+	}
+	p.SetIndexDir("none")
+	p.SetEnv([]string{"GO111MODULE=on", "GOFLAGS=-mod=mod"})
+	p.newCache = NewCache()
+	if err := p.loadCache(p.view.Config, filepath.Join(root, "m")+"/..."); err != nil {
+		t.Fatal(err)
+	}
+
+	c := p.newCache
+	c.RLock()
+	pkg := c.get("example.com/m/a")
+	c.RUnlock()
+	if pkg == nil || pkg.GetImport("fmt") == nil {
+		t.Fatal("the packages are not loaded with the go command of the project")
+	}
+	if data, err := ioutil.ReadFile(log); err != nil || !strings.Contains(string(data), "list -e -json") {
+		t.Errorf("got runs %q of the go command of the project, want the go list of go/packages", data)
+	}
+	if os.Getenv("PATH") != path {
+		t.Error("the PATH of the server is changed")
+	}
+	if dirs := linkDirs(); len(dirs) != links {
+		t.Errorf("the directories linking the go command are left: %v", dirs)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileNames are the names of the configuration files looked up at the
+// root of a project and at the roots of its modules.
+var ConfigFileNames = []string{".bingo.json", ".bingo.toml"}
+
+// isConfigFile reports whether name is the name of a configuration file.
+func isConfigFile(name string) bool {
+	for _, configFile := range ConfigFileNames {
+		if name == configFile {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleTagsFunc returns the build tags of the module rooted at dir. false is
+// returned when the module is built with the build flags of the project.
+type ModuleTagsFunc func(dir string) ([]string, bool)
+
+// SetModuleTags sets how the build tags of each module are found. They are
+// read when the module is loaded, see Reload.
+func (p *Project) SetModuleTags(moduleTags ModuleTagsFunc) {
+	p.moduleTags = moduleTags
+}
+
+// SetConfigHandler sets the function called when the internal file watcher
+// sees a configuration file change.
+func (p *Project) SetConfigHandler(handler func(filename string)) {
+	p.configHandler = handler
+}
+
+// ModuleRoot returns the root directory of the module of the packages of dir,
+// or "" if dir is outside of the modules of the project.
+func (p *Project) ModuleRoot(dir string) string {
+	if m := p.dirModule(dir); m != nil {
+		return m.rootDir
+	}
+	return ""
+}
+
+// dirModule returns the module of the packages of dir, or nil.
+func (p *Project) dirModule(dir string) *module {
+	dir = filepath.ToSlash(dir)
+	// The modules are sorted with the innermost ones first.
+	for _, m := range p.getState().modules {
+		root := filepath.ToSlash(m.rootDir)
+		if dir == root || strings.HasPrefix(dir, root+"/") {
+			return m
+		}
+	}
+	return nil
+}
+
+// moduleFlags returns the build flags of the module m: flags, with the tags of
+// the module if it has its own, and -mod=vendor if it is vendored.
+func (p *Project) moduleFlags(m *module, flags []string) []string {
+	if m.hasTags {
+		flags = withTags(flags, m.tags)
+	}
+	if m.vendored {
+		flags = withVendor(flags)
+	}
+	return flags
+}
+
+// dirFlags returns the build flags of the packages of dir: the ones of its
+// module.
+func (p *Project) dirFlags(dir string, flags []string) []string {
+	if m := p.dirModule(dir); m != nil {
+		return p.moduleFlags(m, flags)
+	}
+	return flags
+}
+
+// dirTags returns the build tags of the packages of dir, when its module has
+// its own.
+func (p *Project) dirTags(dir string) ([]string, bool) {
+	if m := p.dirModule(dir); m != nil {
+		return m.tags, m.hasTags
+	}
+	return nil, false
+}
+
+// withTags returns flags with their -tags flag replaced by tags.
+func withTags(flags []string, tags []string) []string {
+	var result []string
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		if flag == "-tags" {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(flag, "-tags=") {
+			result = append(result, flag)
+		}
+	}
+	if len(tags) > 0 {
+		result = append(result, "-tags", strings.Join(tags, " "))
+	}
+	return result
+}
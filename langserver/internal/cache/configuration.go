@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"go/token"
+	"os"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadWith type-checks the packages of dir from source under the build
+// configuration c. The packages keep the build flags of the view and of
+// their module, such as the workspace tags or -mod=vendor, and get the tags
+// of c in addition. The content of the opened documents is taken into
+// account. It is used to check a package under other build configurations
+// than the view's one.
+func (p *Project) LoadWith(ctx context.Context, dir string, c BuildConfiguration) ([]source.Package, error) {
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	cfg.Overlay = make(map[string][]byte, len(v.Config.Overlay))
+	for filename, content := range v.Config.Overlay {
+		cfg.Overlay[filename] = content
+	}
+	v.mu.Unlock()
+
+	env := cfg.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cfg.Context = ctx
+	cfg.Dir = dir
+	cfg.Env = c.Env(env)
+	flags := p.dirFlags(dir, cfg.BuildFlags)
+	tags := configConfiguration(nil, flags).Tags
+	for _, tag := range c.Tags {
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	cfg.BuildFlags = withTags(flags, tags)
+	cfg.Mode = packages.LoadSyntax
+	cfg.Fset = token.NewFileSet()
+
+	pkgs, err := packages.Load(&cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []source.Package
+	for _, pkg := range pkgs {
+		// Skip the generated main packages of the tests.
+		if strings.HasSuffix(pkg.ID, ".test") || len(pkg.CompiledGoFiles) == 0 {
+			continue
+		}
+		result = append(result, create(pkg))
+	}
+	return result, nil
+}
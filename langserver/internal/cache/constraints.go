@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// knownOS and knownArch are the GOOS and GOARCH values recognized in file
+// names and build constraints.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+	"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+	"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "arm64": true,
+	"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+	"ppc64": true, "ppc64le": true, "riscv64": true, "s390x": true, "wasm": true,
+}
+
+// BuildConfiguration is a GOOS/GOARCH/build tags combination under which
+// packages are type-checked.
+type BuildConfiguration struct {
+	GOOS   string   `json:"goos"`
+	GOARCH string   `json:"goarch"`
+	Tags   []string `json:"tags"`
+}
+
+// String returns the configuration in the goos/goarch+tag+tag form accepted
+// by ParseBuildConfigurations.
+func (c BuildConfiguration) String() string {
+	s := c.GOOS + "/" + c.GOARCH
+	for _, tag := range c.Tags {
+		s += "+" + tag
+	}
+	return s
+}
+
+// Env returns the environment env of the go commands, set for the
+// configuration.
+func (c BuildConfiguration) Env(env []string) []string {
+	return append(append([]string{}, env...), "GOOS="+c.GOOS, "GOARCH="+c.GOARCH)
+}
+
+// BuildFlags returns the build flags flags, set for the configuration.
+func (c BuildConfiguration) BuildFlags(flags []string) []string {
+	return withTags(flags, c.Tags)
+}
+
+// ParseBuildConfigurations parses a comma separated list of configurations
+// such as "linux/amd64,windows/amd64+integration".
+func ParseBuildConfigurations(s string) ([]BuildConfiguration, error) {
+	var configurations []BuildConfiguration
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		parts := strings.Split(field, "+")
+		platform := strings.Split(parts[0], "/")
+		if len(platform) != 2 || platform[0] == "" || platform[1] == "" {
+			return nil, fmt.Errorf("invalid build configuration %q, expected goos/goarch[+tag...]", field)
+		}
+		configurations = append(configurations, BuildConfiguration{
+			GOOS:   platform[0],
+			GOARCH: platform[1],
+			Tags:   parts[1:],
+		})
+	}
+	return configurations, nil
+}
+
+// matchFile reports whether the file, with the given content, is part of the
+// build under the configuration.
+func (c BuildConfiguration) matchFile(filename string, content []byte) bool {
+	ctxt := build.Default
+	ctxt.GOOS = c.GOOS
+	ctxt.GOARCH = c.GOARCH
+	ctxt.BuildTags = c.Tags
+	if c.GOOS != build.Default.GOOS || c.GOARCH != build.Default.GOARCH {
+		// The go command disables cgo when cross-compiling.
+		ctxt.CgoEnabled = false
+	}
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		if path == filename && content != nil {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		}
+		return os.Open(path)
+	}
+
+	match, err := ctxt.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+	return err == nil && match
+}
+
+// fileConfiguration returns a configuration, derived from host, under which
+// the file is part of the build. The GOOS and GOARCH are taken from the file
+// name or the build constraints, and the other words of the build
+// constraints are added as tags. false is returned if no such configuration
+// is found.
+func fileConfiguration(host BuildConfiguration, filename string, content []byte) (BuildConfiguration, bool) {
+	if host.matchFile(filename, content) {
+		return host, true
+	}
+
+	c := BuildConfiguration{GOOS: host.GOOS, GOARCH: host.GOARCH, Tags: append([]string{}, host.Tags...)}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(filename), ".go"), "_test")
+	parts := strings.Split(name, "_")
+	if n := len(parts); n >= 2 {
+		if knownArch[parts[n-1]] {
+			c.GOARCH = parts[n-1]
+			if n >= 3 && knownOS[parts[n-2]] {
+				c.GOOS = parts[n-2]
+			}
+		} else if knownOS[parts[n-1]] {
+			c.GOOS = parts[n-1]
+		}
+	}
+	if c.matchFile(filename, content) {
+		return c, true
+	}
+
+	var oses, arches []string
+	for _, word := range constraintWords(content) {
+		switch {
+		case knownOS[word]:
+			oses = append(oses, word)
+		case knownArch[word]:
+			arches = append(arches, word)
+		case word == "cgo" || word == "gc" || word == "gccgo" || strings.HasPrefix(word, "go1."):
+		default:
+			if !contains(c.Tags, word) {
+				c.Tags = append(c.Tags, word)
+			}
+		}
+	}
+	if len(oses) > 0 && !contains(oses, c.GOOS) {
+		c.GOOS = oses[0]
+	}
+	if len(arches) > 0 && !contains(arches, c.GOARCH) {
+		c.GOARCH = arches[0]
+	}
+	if c.matchFile(filename, content) {
+		return c, true
+	}
+	return host, false
+}
+
+// constraintWords returns the words which are not negated in the build
+// constraints of a file.
+func constraintWords(content []byte) []string {
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+
+		var expr string
+		switch {
+		case strings.HasPrefix(line, "//go:build "):
+			expr = strings.TrimPrefix(line, "//go:build ")
+		case strings.HasPrefix(line, "// +build "):
+			expr = strings.TrimPrefix(line, "// +build ")
+		default:
+			continue
+		}
+
+		fields := strings.FieldsFunc(expr, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == '(' || r == ')' || r == '&' || r == '|'
+		})
+		for _, field := range fields {
+			if !strings.HasPrefix(field, "!") {
+				words = append(words, field)
+			}
+		}
+	}
+	return words
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HostConfiguration returns the configuration of the main view.
+func (p *Project) HostConfiguration() BuildConfiguration {
+	v := p.getView()
+	v.mu.Lock()
+	env, flags := v.Config.Env, v.Config.BuildFlags
+	v.mu.Unlock()
+	return configConfiguration(env, flags)
+}
+
+// configConfiguration returns the configuration of the go commands run with
+// env and the build flags flags.
+func configConfiguration(env []string, flags []string) BuildConfiguration {
+	c := BuildConfiguration{GOOS: build.Default.GOOS, GOARCH: build.Default.GOARCH}
+	for _, env := range env {
+		if strings.HasPrefix(env, "GOOS=") {
+			c.GOOS = strings.TrimPrefix(env, "GOOS=")
+		} else if strings.HasPrefix(env, "GOARCH=") {
+			c.GOARCH = strings.TrimPrefix(env, "GOARCH=")
+		}
+	}
+
+	for i, flag := range flags {
+		if flag == "-tags" && i+1 < len(flags) {
+			c.Tags = splitTags(flags[i+1])
+		} else if strings.HasPrefix(flag, "-tags=") {
+			c.Tags = splitTags(strings.TrimPrefix(flag, "-tags="))
+		}
+	}
+	return c
+}
+
+func splitTags(tags string) []string {
+	return strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// ViewFor returns the view which type-checks the file uri. It is the main
+// view, unless the file is excluded by the build configuration of the main
+// view.
+func (p *Project) ViewFor(uri span.URI) source.View {
+	return p.viewFor(uri)
+}
+
+func (p *Project) viewFor(uri span.URI) *View {
+	p.viewsMu.Lock()
+	defer p.viewsMu.Unlock()
+
+	if v, ok := p.fileViews[uri]; ok {
+		return v
+	}
+	return p.getView()
+}
+
+// RouteFile selects the view of the file uri from its build constraints and
+// its content. The configuration of the selected view is returned when it is
+// not the main view, along with whether the view of the file has changed.
+func (p *Project) RouteFile(ctx context.Context, uri span.URI, content []byte) (configuration string, changed bool) {
+	filename, err := uri.Filename()
+	if err != nil || !strings.HasSuffix(filename, ".go") {
+		return "", false
+	}
+
+	// The files of a module with its own build tags are matched against
+	// them, the main view loads their packages with them.
+	host := p.HostConfiguration()
+	if tags, ok := p.dirTags(filepath.Dir(filename)); ok {
+		host.Tags = tags
+	}
+	c, ok := fileConfiguration(host, filename, content)
+	main := p.getView()
+	v := main
+	if ok && c.String() != host.String() {
+		v = p.secondaryView(c)
+		configuration = c.String()
+	}
+
+	p.viewsMu.Lock()
+	old, ok := p.fileViews[uri]
+	if !ok {
+		old = main
+	}
+	if v == main {
+		delete(p.fileViews, uri)
+	} else {
+		p.fileViews[uri] = v
+	}
+	p.dropSecondaryViews()
+	p.viewsMu.Unlock()
+
+	if old != v {
+		// Drop the content of the document from the view it leaves.
+		_ = old.SetContent(ctx, uri, nil)
+		return configuration, true
+	}
+	return configuration, false
+}
+
+// releaseFile forgets the view of the closed file uri.
+func (p *Project) releaseFile(uri span.URI) {
+	p.viewsMu.Lock()
+	defer p.viewsMu.Unlock()
+
+	if _, ok := p.fileViews[uri]; ok {
+		delete(p.fileViews, uri)
+		p.dropSecondaryViews()
+	}
+}
+
+// dropSecondaryViews discards the secondary views which no longer type-check
+// any file. It is called with p.viewsMu held.
+func (p *Project) dropSecondaryViews() {
+	used := make(map[*View]bool, len(p.fileViews))
+	for _, v := range p.fileViews {
+		used[v] = true
+	}
+	for key, v := range p.secondaryViews {
+		if used[v] {
+			continue
+		}
+		v.mu.Lock()
+		v.cancel()
+		v.mu.Unlock()
+		delete(p.secondaryViews, key)
+	}
+}
+
+// secondaryView returns the view type-checking with configuration c,
+// creating it on first use. Secondary views don't share the global cache,
+// whose packages are built with the main configuration.
+func (p *Project) secondaryView(c BuildConfiguration) *View {
+	p.viewsMu.Lock()
+	defer p.viewsMu.Unlock()
+
+	key := c.String()
+	if v, ok := p.secondaryViews[key]; ok {
+		return v
+	}
+
+	main := p.getView()
+	main.mu.Lock()
+	cfg := main.Config
+	main.mu.Unlock()
+
+	cfg.Overlay = make(map[string][]byte)
+	env := cfg.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cfg.Env = c.Env(env)
+	cfg.BuildFlags = c.BuildFlags(cfg.BuildFlags)
+
+	v := NewView(&cfg)
+	// The packages of a module keep its other flags, such as -mod=vendor,
+	// with the tags of the configuration.
+	v.dirFlags = func(dir string, flags []string) []string {
+		return c.BuildFlags(p.dirFlags(dir, flags))
+	}
+	v.adhocDir = isAdhocDir
+	p.secondaryViews[key] = v
+	return v
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestFileConfiguration(t *testing.T) {
+	host := BuildConfiguration{GOOS: "linux", GOARCH: "amd64"}
+
+	tests := []struct {
+		filename string
+		content  string
+		want     string
+		ok       bool
+	}{
+		{"/src/a.go", "package a\n", "linux/amd64", true},
+		{"/src/a_windows.go", "package a\n", "windows/amd64", true},
+		{"/src/a_darwin_arm64_test.go", "package a\n", "darwin/arm64", true},
+		{"/src/a.go", "//go:build integration\n\npackage a\n", "linux/amd64+integration", true},
+		{"/src/a.go", "// +build windows,!cgo\n\npackage a\n", "windows/amd64", true},
+		{"/src/a.go", "//go:build (darwin || freebsd) && e2e\n\npackage a\n", "darwin/amd64+e2e", true},
+		{"/src/a.go", "//go:build !linux && !windows && !darwin && !freebsd\n\npackage a\n", "linux/amd64", false},
+	}
+
+	for _, test := range tests {
+		got, ok := fileConfiguration(host, test.filename, []byte(test.content))
+		if got.String() != test.want || ok != test.ok {
+			t.Errorf("fileConfiguration(%q, %q) = %q, %t, want %q, %t", test.filename, test.content, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestSecondaryViews(t *testing.T) {
+	goos := "windows"
+	if build.Default.GOOS == goos {
+		goos = "linux"
+	}
+	p := &Project{
+		view:           NewView(&packages.Config{Env: []string{}, BuildFlags: []string{"-tags", "a"}}),
+		secondaryViews: make(map[string]*View),
+		fileViews:      make(map[span.URI]*View),
+	}
+	filename := "/src/a_" + goos + ".go"
+	uri := span.FileURI(filename)
+
+	ctx := context.Background()
+	configuration, changed := p.RouteFile(ctx, uri, []byte("package a\n"))
+	if !changed || configuration != goos+"/"+build.Default.GOARCH+"+a" {
+		t.Fatalf("got configuration %q, changed %t", configuration, changed)
+	}
+	v := p.viewFor(uri)
+	if v == p.view || v.dirFlags == nil {
+		t.Fatalf("got no secondary view with the build flags of the modules")
+	}
+	if got := v.dirFlags("/src", []string{"-tags", "b"}); !reflect.DeepEqual(got, []string{"-tags", "a"}) {
+		t.Errorf("got build flags %v, want the tags of the configuration", got)
+	}
+
+	// The view is dropped with the last file it type-checks.
+	p.DidClose(filename)
+	if len(p.secondaryViews) != 0 || p.viewFor(uri) != p.view {
+		t.Errorf("the secondary view of a closed file is kept")
+	}
+}
+
+func TestLoadWithWorkspaceFlags(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-loadwith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":         "module example.com/m\n",
+		"a/ws.go":        "// +build ws\n\npackage a\n\nconst X = 1\n",
+		"a/a_windows.go": "// +build integration\n\npackage a\n\nvar _ = X\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, root, []string{"-tags", "ws"})
+	p.SetEnv([]string{"GO111MODULE=on", "GOFLAGS=-mod=mod"})
+	p.setState(loadState{modules: []*module{newModule(p, root)}})
+
+	// The workspace tags are kept, the ones of the configuration added.
+	c := BuildConfiguration{GOOS: "windows", GOARCH: "amd64", Tags: []string{"integration"}}
+	pkgs, err := p.LoadWith(context.Background(), filepath.Join(root, "a"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].GetFilenames()) != 2 || len(pkgs[0].GetErrors()) != 0 {
+		t.Fatalf("got packages %v, want a with both files and without errors", pkgs)
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"go/token"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// DependencyCache shares the dependencies outside of the projects between
+// the projects of a workspace, so that a dependency used by several of them
+// is only loaded once. The projects sharing it use its file set.
+type DependencyCache struct {
+	fset *token.FileSet
+
+	mu       sync.Mutex
+	packages map[string]*dependency
+}
+
+// dependency is a loaded dependency shared by the projects.
+type dependency struct {
+	lpkg *packages.Package
+	// restored is set when the types of lpkg are read from export data, its
+	// syntax is loaded on demand.
+	restored bool
+}
+
+// NewDependencyCache returns an empty dependency cache.
+func NewDependencyCache() *DependencyCache {
+	return &DependencyCache{fset: token.NewFileSet(), packages: make(map[string]*dependency)}
+}
+
+// SetDependencyCache makes the project share the dependencies of deps with
+// the other projects using it. It must be called before Init.
+func (p *Project) SetDependencyCache(deps *DependencyCache) {
+	p.deps = deps
+
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.Config.Fset = deps.fset
+}
+
+// dependencyKey returns the key of lpkg in the dependency cache. The same
+// package may be built from other files by another project, in another
+// version or with other build tags.
+func dependencyKey(lpkg *packages.Package) string {
+	files := lpkg.CompiledGoFiles
+	if len(files) == 0 {
+		files = lpkg.GoFiles
+	}
+	return lpkg.ID + "\x00" + strings.Join(files, "\x00")
+}
+
+// get returns the shared dependency matching lpkg, whose imports must all be
+// shared, so that the types of the other projects are used consistently.
+func (c *DependencyCache) get(lpkg *packages.Package, shared map[*packages.Package]bool) *dependency {
+	if c == nil {
+		return nil
+	}
+	for _, imp := range lpkg.Imports {
+		if !shared[imp] && imp.PkgPath != "unsafe" {
+			return nil
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.packages[dependencyKey(lpkg)]
+}
+
+// put shares the dependency lpkg with the other projects, if its imports
+// are all shared. It reports whether lpkg is shared.
+func (c *DependencyCache) put(lpkg *packages.Package, restored bool, shared map[*packages.Package]bool) bool {
+	if c == nil || lpkg.IllTyped {
+		return false
+	}
+	for _, imp := range lpkg.Imports {
+		if !shared[imp] && imp.PkgPath != "unsafe" {
+			return false
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := dependencyKey(lpkg)
+	if _, ok := c.packages[key]; ok {
+		return false
+	}
+	c.packages[key] = &dependency{lpkg: lpkg, restored: restored}
+	return true
+}
+
+// share loads lpkg from the shared dependency dep.
+func (dep *dependency) share(lpkg *packages.Package) {
+	lpkg.Types = dep.lpkg.Types
+	lpkg.TypesInfo = dep.lpkg.TypesInfo
+	lpkg.Syntax = dep.lpkg.Syntax
+	lpkg.Errors = dep.lpkg.Errors
+	lpkg.IllTyped = dep.lpkg.IllTyped
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// goBinaryEnv is the environment variable naming the go command of the
+// project in the environment of its packages driver, see SetGoBinary.
+const goBinaryEnv = "BINGO_GO_BINARY"
+
+// driverRequest is the request read by the packages driver, see the
+// GOPACKAGESDRIVER protocol of go/packages.
+type driverRequest struct {
+	Mode       packages.LoadMode `json:"mode"`
+	Env        []string          `json:"env"`
+	BuildFlags []string          `json:"build_flags"`
+	Tests      bool              `json:"tests"`
+	Overlay    map[string][]byte `json:"overlay"`
+}
+
+// driverResponse is the response written by the packages driver.
+type driverResponse struct {
+	Sizes    *types.StdSizes
+	Compiler string
+	Arch     string
+	Roots    []string `json:",omitempty"`
+	Packages []*packages.Package
+}
+
+// IsPackagesDriver reports whether the server runs as the packages driver of
+// a project, see RunPackagesDriver.
+func IsPackagesDriver() bool {
+	return os.Getenv(goBinaryEnv) != ""
+}
+
+// RunPackagesDriver lists the packages of the patterns with the go command of
+// the project, for the packages.Load calls of a project whose go command is
+// set by SetGoBinary. go/packages runs the go command of the PATH: the
+// driver runs in a process of its own, whose PATH starts with the go command
+// of the project. The request is read from stdin, the response written to
+// stdout.
+func RunPackagesDriver(patterns []string) error {
+	var req driverRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		return fmt.Errorf("failed to decode the driver request: %v", err)
+	}
+
+	goBinary := getenv(req.Env, goBinaryEnv)
+	dir, cleanup, err := goBinaryDir(goBinary)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	path := dir + string(os.PathListSeparator) + getenv(req.Env, "PATH")
+	if err := os.Setenv("PATH", path); err != nil {
+		return err
+	}
+
+	cfg := &packages.Config{
+		Mode:       packages.LoadImports,
+		Env:        append(req.Env, "PATH="+path, "GOPACKAGESDRIVER=off"),
+		BuildFlags: req.BuildFlags,
+		Tests:      req.Tests,
+		Overlay:    req.Overlay,
+	}
+	roots, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return err
+	}
+
+	resp := driverResponse{Compiler: "gc", Arch: runtime.GOARCH}
+	cmd := exec.Command(goBinary, "env", "GOARCH")
+	cmd.Env = cfg.Env
+	if out, err := cmd.Output(); err == nil {
+		resp.Arch = strings.TrimSpace(string(out))
+	}
+	if sizes := types.SizesFor(resp.Compiler, resp.Arch); sizes != nil {
+		resp.Sizes = &types.StdSizes{
+			WordSize: sizes.Sizeof(types.Typ[types.Uintptr]),
+			MaxAlign: sizes.Alignof(types.Typ[types.Int64]),
+		}
+	}
+
+	seen := make(map[string]bool)
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if seen[pkg.ID] {
+			return
+		}
+		seen[pkg.ID] = true
+		resp.Packages = append(resp.Packages, pkg)
+		for _, imp := range pkg.Imports {
+			visit(imp)
+		}
+	}
+	for _, root := range roots {
+		resp.Roots = append(resp.Roots, root.ID)
+		visit(root)
+	}
+	return json.NewEncoder(os.Stdout).Encode(&resp)
+}
+
+// goBinaryDir returns a directory holding the go command goBinary under the
+// name go/packages runs. When goBinary is named otherwise, it is linked from
+// a temporary directory, removed by cleanup.
+func goBinaryDir(goBinary string) (dir string, cleanup func(), err error) {
+	exe := "go"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if filepath.Base(goBinary) == exe {
+		return filepath.Dir(goBinary), func() {}, nil
+	}
+
+	dir, err = ioutil.TempDir("", "bingo-go")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := os.Symlink(goBinary, filepath.Join(dir, exe)); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+// driverEnv returns the variables of the environment of the go commands
+// which run the packages driver with the go command goBinary.
+func driverEnv(goBinary string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return []string{"GOPACKAGESDRIVER=" + exe, goBinaryEnv + "=" + goBinary}, nil
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+)
+
+// DependencyMode selects how the dependencies outside of the project are
+// loaded into the global cache.
+type DependencyMode string
+
+const (
+	// DependencySource parses and type-checks the dependencies, like the
+	// packages of the project.
+	DependencySource DependencyMode = "source"
+	// DependencyExport reads the types of the dependencies from their
+	// compiled export data. Their syntax and type information are only
+	// loaded from source when a request needs them.
+	DependencyExport DependencyMode = "export"
+)
+
+// SetDependencyMode selects how the dependencies outside of the project are
+// loaded.
+func (p *Project) SetDependencyMode(mode DependencyMode) {
+	p.dependencyMode = mode
+}
+
+// isDependency reports whether lpkg is a dependency outside of the project,
+// or vendored in it.
+func (p *Project) isDependency(lpkg *packages.Package) bool {
+	files := lpkg.CompiledGoFiles
+	if len(files) == 0 {
+		files = lpkg.GoFiles
+	}
+	if len(files) == 0 {
+		return false
+	}
+	dir := filepath.Dir(files[0])
+	return !p.isInsideProject(dir) || p.isVendored(dir)
+}
+
+// exportFiles returns the files of the compiled export data of the
+// dependencies of pkgs outside of the project, by package path. They are
+// built by the go command if needed.
+func (p *Project) exportFiles(ctx context.Context, cfg *packages.Config, pkgs []*packages.Package) map[string]string {
+	var paths []string
+	for _, lpkg := range pkgs {
+		// The test variants are never dependencies.
+		if lpkg.ID == lpkg.PkgPath && lpkg.PkgPath != "unsafe" && p.isDependency(lpkg) {
+			paths = append(paths, lpkg.PkgPath)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	args := append([]string{"list", "-e", "-export", "-f", "{{.ImportPath}} {{.Export}}"}, cfg.BuildFlags...)
+	stdout, err := p.invokeGo(ctx, cfg.Dir, append(args, paths...)...)
+	if err != nil {
+		p.notifyLog(err.Error())
+		return nil
+	}
+
+	files := make(map[string]string)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) == 2 && fields[1] != "" {
+			files[fields[0]] = fields[1]
+		}
+	}
+	return files
+}
+
+// readExportFile reads the types of the package pkgPath from the export data
+// file filename. The objects of the packages in imports are shared.
+func readExportFile(filename string, fset *token.FileSet, imports map[string]*types.Package, pkgPath string) (*types.Package, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gcexportdata.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	return gcexportdata.Read(r, fset, imports, pkgPath)
+}
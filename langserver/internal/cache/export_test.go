@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDependencyExport(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nimport \"strings\"\n\nvar _ = strings.Index\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, root, nil)
+	p.SetIndexDir("none")
+	p.SetDependencyMode(DependencyExport)
+	p.SetDependencyCache(NewDependencyCache())
+	p.newCache = NewCache()
+	cfg := p.view.Config
+	cfg.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod")
+	if err := p.loadCache(cfg, root+"/..."); err != nil {
+		t.Fatal(err)
+	}
+
+	p.newCache.RLock()
+	a, strs := p.newCache.get("example.com/m/a"), p.newCache.get("strings")
+	p.newCache.RUnlock()
+	if a == nil || strs == nil {
+		t.Fatal("the packages are not loaded")
+	}
+	if !a.loaded() {
+		t.Error("the package of the project is not type-checked from source")
+	}
+	if strs.loaded() {
+		// The loader falls back to the source of the dependencies whose
+		// export data it can't read.
+		t.Skip("the export data of the go command is not readable by go/gcexportdata")
+	}
+
+	// The source of the dependency is loaded when a request drills into
+	// it, and the objects used by the project are found in its syntax.
+	obj := strs.GetTypes().Scope().Lookup("Index")
+	for ident, use := range a.GetTypesInfo().Uses {
+		if ident.Name == "Index" && use != obj {
+			t.Errorf("the use of strings.Index refers to %v, not to the types of strings", use)
+		}
+	}
+	_, ident, err := source.GetObjectPathNode(a, a.GetFileSet(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strs.loaded() {
+		t.Error("the source of the dependency is not loaded")
+	}
+	var decl *ast.FuncDecl
+	for _, f := range strs.GetSyntax() {
+		for _, d := range f.Decls {
+			if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "Index" {
+				decl = fn
+			}
+		}
+	}
+	if decl == nil || ident != decl.Name {
+		t.Errorf("got %v for strings.Index, want the name of its declaration", ident)
+	}
+}
+
+func TestRestoredDependencySource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"dep/dep.go": "package dep\n\n// F is a function.\nfunc F() {}\n",
+		"a/a.go":     "package a\n\nimport \"dep\"\n\nvar _ = dep.F\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, dir, nil)
+	p.newCache = NewCache()
+	cfg := p.view.Config
+	sizes := configSizes(&cfg)
+
+	// The types of dep are read from export data, as the types of the
+	// dependencies outside of the project.
+	dep := &packages.Package{ID: "dep", Name: "dep", PkgPath: "dep", CompiledGoFiles: []string{filepath.Join(dir, "dep", "dep.go")}, Fset: cfg.Fset}
+	checkPackage(&cfg, dep, dep.CompiledGoFiles, sizes)
+	var buf bytes.Buffer
+	if err := gcexportdata.Write(&buf, cfg.Fset, dep.Types); err != nil {
+		t.Fatal(err)
+	}
+	dep = &packages.Package{ID: "dep", Name: "dep", PkgPath: "dep", CompiledGoFiles: dep.CompiledGoFiles, Fset: cfg.Fset}
+	dep.Types, err = gcexportdata.Read(&buf, cfg.Fset, make(map[string]*types.Package), "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.addToCache(&cfg, dep, sizes, true, nil)
+
+	a := &packages.Package{ID: "a", Name: "a", PkgPath: "a", CompiledGoFiles: []string{filepath.Join(dir, "a", "a.go")}, Imports: map[string]*packages.Package{"dep": dep}, Fset: cfg.Fset}
+	checkPackage(&cfg, a, a.CompiledGoFiles, sizes)
+	p.addToCache(&cfg, a, sizes, false, nil)
+
+	p.newCache.RLock()
+	aPkg, depPkg := p.newCache.get("a"), p.newCache.get("dep")
+	p.newCache.RUnlock()
+	if depPkg.loaded() {
+		t.Fatal("the source of the dependency is loaded with its types")
+	}
+
+	f := depPkg.GetTypes().Scope().Lookup("F")
+	nodes, ident, err := source.GetObjectPathNode(aPkg, aPkg.GetFileSet(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !depPkg.loaded() {
+		t.Error("the source of the dependency is not loaded on demand")
+	}
+	if ident.Name != "F" || depPkg.GetTypesInfo().Defs[ident] != f {
+		t.Errorf("got %v for dep.F, want the name of its declaration", ident)
+	}
+	if doc := source.PullComments(nodes); doc != "F is a function.\n" {
+		t.Errorf("got the documentation %q for dep.F", doc)
+	}
+}
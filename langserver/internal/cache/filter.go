@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// DefaultExcludePatterns are the directories skipped by default when walking
+// the project.
+var DefaultExcludePatterns = []string{".git", ".svn", ".hg", ".vscode", ".idea", "node_modules", vendor}
+
+// DefaultMaxDepth is the default maximum depth of the walked directories.
+const DefaultMaxDepth = 8
+
+// ignoreFiles are the files listing the ignored paths of their directory, in
+// the .gitignore syntax.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// WalkOptions select the directories of the project which are walked for
+// go.mod files, watched and loaded.
+//
+// The patterns are globs matched against the slash-separated paths relative
+// to the project root: a pattern without a slash matches a directory name at
+// any depth, "**" matches any number of directories.
+type WalkOptions struct {
+	// Include restricts the go.mod files and the packages loaded to the
+	// matching directories and below, when not empty.
+	Include []string
+	// Exclude skips the matching directories and below.
+	Exclude []string
+	// MaxDepth is the maximum depth of the directories below the root
+	// walked for go.mod files and watched, 0 for no limit. The packages
+	// below it are loaded all the same.
+	MaxDepth int
+	// IgnoreFiles skips the directories ignored by the .gitignore and
+	// .ignore files of the project.
+	IgnoreFiles bool
+}
+
+// DefaultWalkOptions returns the walk options used unless SetWalkOptions is
+// called.
+func DefaultWalkOptions() WalkOptions {
+	return WalkOptions{Exclude: DefaultExcludePatterns, MaxDepth: DefaultMaxDepth}
+}
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+}
+
+// walkFilter decides which directories of the project are walked.
+type walkFilter struct {
+	root    string
+	options WalkOptions
+
+	mu sync.Mutex
+	// ignores caches the rules of the ignore files, by directory.
+	ignores map[string][]ignoreRule
+}
+
+func newWalkFilter(root string, options WalkOptions) *walkFilter {
+	return &walkFilter{
+		root:    util.LowerDriver(filepath.ToSlash(root)),
+		options: options,
+		ignores: make(map[string][]ignoreRule),
+	}
+}
+
+// relative returns the path of dir relative to the root, and whether dir is
+// inside of the root.
+func (f *walkFilter) relative(dir string) (string, bool) {
+	dir = util.LowerDriver(filepath.ToSlash(dir))
+	if dir == f.root {
+		return "", true
+	}
+	if !strings.HasPrefix(dir, f.root+"/") {
+		return "", false
+	}
+	return dir[len(f.root)+1:], true
+}
+
+// skip reports whether dir and the directories below it are not walked for
+// go.mod files and watched.
+func (f *walkFilter) skip(dir string) bool {
+	rel, ok := f.relative(dir)
+	if !ok || rel == "" {
+		return false
+	}
+
+	if f.options.MaxDepth > 0 && strings.Count(rel, "/") >= f.options.MaxDepth {
+		return true
+	}
+	return f.excluded(dir)
+}
+
+// excluded reports whether dir and the directories below it are excluded
+// from the project. Unlike skip, it ignores the maximum depth, which only
+// bounds the walks: the packages below it are loaded all the same.
+func (f *walkFilter) excluded(dir string) bool {
+	rel, ok := f.relative(dir)
+	if !ok || rel == "" {
+		return false
+	}
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		for _, pattern := range f.options.Exclude {
+			if matchPattern(pattern, prefix) {
+				return true
+			}
+		}
+		if f.options.IgnoreFiles && f.ignored(segments[:i+1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// included reports whether the go.mod files and the packages of dir are
+// taken into account.
+func (f *walkFilter) included(dir string) bool {
+	if len(f.options.Include) == 0 {
+		return true
+	}
+
+	rel, ok := f.relative(dir)
+	if !ok {
+		return true
+	}
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		for _, pattern := range f.options.Include {
+			if matchPattern(pattern, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ignored reports whether the directory of the root relative path segments
+// is ignored by the ignore files of its parents. As with git, the last
+// matching rule wins.
+func (f *walkFilter) ignored(segments []string) bool {
+	ignored := false
+	for i := 0; i < len(segments); i++ {
+		dir := path.Join(append([]string{f.root}, segments[:i]...)...)
+		rel := strings.Join(segments[i:], "/")
+		for _, rule := range f.ignoreRules(dir) {
+			if matchPattern(rule.pattern, rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (f *walkFilter) ignoreRules(dir string) []ignoreRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rules, ok := f.ignores[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(filepath.FromSlash(dir), name))...)
+	}
+	f.ignores[dir] = rules
+	return rules
+}
+
+// forget drops the cached rules of the ignore file filename, after it
+// changed.
+func (f *walkFilter) forget(filename string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.ignores, util.LowerDriver(filepath.ToSlash(filepath.Dir(filename))))
+}
+
+func isIgnoreFile(name string) bool {
+	for _, ignoreFile := range ignoreFiles {
+		if name == ignoreFile {
+			return true
+		}
+	}
+	return false
+}
+
+func readIgnoreFile(filename string) []ignoreRule {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{pattern: line}
+		if strings.HasPrefix(line, "!") {
+			rule = ignoreRule{pattern: line[1:], negate: true}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matchPattern reports whether the root relative path rel matches the glob
+// pattern. A pattern without a slash, but a trailing one, matches the last
+// element of rel. Otherwise it matches rel as a whole, "**" matching any
+// number of elements.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+
+	pattern = strings.TrimPrefix(pattern, "/")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"node_modules", "web/node_modules", true},
+		{"testdata", "a/testdata", true},
+		{"test*", "a/testdata", true},
+		{"build/", "build", true},
+		{"/build", "a/build", false},
+		{"a/build", "a/build", true},
+		{"**/gen", "a/b/gen", true},
+		{"**/gen", "gen", true},
+		{"a/**/gen", "a/gen", true},
+		{"a/**/gen", "b/gen", false},
+		{"a/*", "a/b/c", false},
+	}
+
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.rel); got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %t, want %t", test.pattern, test.rel, got, test.want)
+		}
+	}
+}
+
+func TestWalkFilter(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(filepath.Join(root, ".gitignore"), []byte("# outputs\nout/\ngen\n!keep/gen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newWalkFilter(root, WalkOptions{
+		Include:     []string{"services/**"},
+		Exclude:     []string{"vendor"},
+		MaxDepth:    3,
+		IgnoreFiles: true,
+	})
+
+	skips := map[string]bool{
+		"services":             false,
+		"services/api/vendor":  true,
+		"out":                  true,
+		"out/x":                true,
+		"services/gen":         true,
+		"keep/gen":             false,
+		"services/a/b":         false,
+		"services/a/b/c":       true,
+		"services/api/handler": false,
+	}
+	for rel, want := range skips {
+		if got := f.skip(filepath.Join(root, rel)); got != want {
+			t.Errorf("skip(%q) = %t, want %t", rel, got, want)
+		}
+	}
+
+	// The maximum depth only bounds the walks.
+	for rel, want := range map[string]bool{"services/a/b/c": false, "services/api/vendor": true} {
+		if got := f.excluded(filepath.Join(root, rel)); got != want {
+			t.Errorf("excluded(%q) = %t, want %t", rel, got, want)
+		}
+	}
+
+	includes := map[string]bool{
+		"services":     true,
+		"services/api": true,
+		"tools":        false,
+	}
+	for rel, want := range includes {
+		if got := f.included(filepath.Join(root, rel)); got != want {
+			t.Errorf("included(%q) = %t, want %t", rel, got, want)
+		}
+	}
+}
@@ -5,7 +5,10 @@ package cache
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -13,6 +16,17 @@ import (
 type fsSubject struct {
 	observer Observer
 	watched  int
+
+	// dirs is the set of the watched directories.
+	dirs map[string]bool
+
+	// fallback takes over once the watches are exhausted, if not nil.
+	fallback  Subject
+	exhausted bool
+}
+
+func newSubject(observer Observer, fallback Subject) Subject {
+	return &fsSubject{observer: observer, fallback: fallback}
 }
 
 func (s *fsSubject) notify() {
@@ -22,7 +36,12 @@ func (s *fsSubject) notify() {
 		return
 	}
 
+	s.dirs = make(map[string]bool)
 	s.watch(s.observer.root(), watcher)
+	if s.exhausted && s.fallback != nil {
+		s.switchToFallback(watcher)
+		return
+	}
 
 	s.observer.notifyLog(fmt.Sprintf("fsnotify watch dir number: %d", s.watched))
 
@@ -31,15 +50,25 @@ func (s *fsSubject) notify() {
 			_ = watcher.Close()
 		}()
 
+		changes := newBatcher(coalesceDelay, s.observer.update)
 		for {
 			select {
+			case <-s.observer.getContext().Done():
+				return
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
 
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					s.observer.update(event.Name)
+				if !s.handle(event, watcher) {
+					continue
+				}
+				changes.add(event.Name)
+				if s.exhausted && s.fallback != nil {
+					// The fallback only sees the changes from now on.
+					changes.flush()
+					s.switchToFallback(watcher)
+					return
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -51,14 +80,48 @@ func (s *fsSubject) notify() {
 	}()
 }
 
+// handle updates the watches for event, and reports whether the event may
+// change the packages of the project.
+func (s *fsSubject) handle(event fsnotify.Event, watcher *fsnotify.Watcher) bool {
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+			if s.observer.skipDir(event.Name) {
+				return false
+			}
+			s.watch(event.Name, watcher)
+		}
+		return true
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		s.unwatch(event.Name, watcher)
+		return true
+	case event.Op&(fsnotify.Write|fsnotify.Chmod) != 0:
+		return true
+	}
+
+	return false
+}
+
 func (s *fsSubject) watch(rootDir string, watcher *fsnotify.Watcher) {
+	if s.dirs[rootDir] || s.exhausted {
+		return
+	}
+
 	err := watcher.Add(rootDir)
+	if err == syscall.ENOSPC {
+		// The inotify watches of the user are exhausted, see
+		// /proc/sys/fs/inotify/max_user_watches.
+		s.exhausted = true
+		s.observer.notifyLog(fmt.Sprintf("fsnotify watch %s: %s", rootDir, err))
+		return
+	}
 	if err != nil {
 		s.observer.notifyLog(err.Error())
 	}
 
 	if err == nil {
 		s.watched++
+		s.dirs[rootDir] = true
 	}
 	//p.NotifyLog(fmt.Sprintf("watch %s", rootDir))
 
@@ -69,13 +132,33 @@ func (s *fsSubject) watch(rootDir string, watcher *fsnotify.Watcher) {
 	}
 
 	for _, fi := range files {
-		if isExclude(fi.Name()) {
-			continue
-		}
-
 		fullpath := filepath.Join(rootDir, fi.Name())
-		if fi.IsDir() {
+		if fi.IsDir() && !s.observer.skipDir(fullpath) {
 			s.watch(fullpath, watcher)
 		}
 	}
 }
+
+// switchToFallback closes the watcher, and lets the fallback subject watch
+// the files instead.
+func (s *fsSubject) switchToFallback(watcher *fsnotify.Watcher) {
+	_ = watcher.Close()
+	s.observer.notifyLog(fmt.Sprintf("fsnotify watches exhausted after %d dirs, switch to polling", s.watched))
+	s.fallback.notify()
+}
+
+// unwatch drops the watches of the removed or renamed directory rootDir and
+// of its subdirectories.
+func (s *fsSubject) unwatch(rootDir string, watcher *fsnotify.Watcher) {
+	prefix := rootDir + string(filepath.Separator)
+	for dir := range s.dirs {
+		if dir != rootDir && !strings.HasPrefix(dir, prefix) {
+			continue
+		}
+
+		// The watch of a deleted directory is already gone.
+		_ = watcher.Remove(dir)
+		delete(s.dirs, dir)
+		s.watched--
+	}
+}
@@ -12,6 +12,12 @@ type fsSubject struct {
 	observer Observer
 }
 
+// newSubject returns the fsevents subject. fsevents watches the whole tree
+// with a single stream, so there are no watches to exhaust and no fallback.
+func newSubject(observer Observer, fallback Subject) Subject {
+	return &fsSubject{observer: observer}
+}
+
 func (o *fsSubject) notify() {
 	dev, err := fsevents.DeviceForPath(o.observer.root())
 	if err != nil {
@@ -31,6 +37,7 @@ func (o *fsSubject) notify() {
 			es.Stop()
 		}()
 
+		changes := newBatcher(coalesceDelay, o.observer.update)
 		for {
 			select {
 			case <-o.observer.getContext().Done():
@@ -41,13 +48,10 @@ func (o *fsSubject) notify() {
 				}
 
 				for _, event := range events {
-					if event.Flags&fsevents.ItemIsFile != 0 &&
-						event.Flags&fsevents.ItemCreated != 0 ||
-						event.Flags&fsevents.ItemModified != 0 ||
-						event.Flags&fsevents.ItemRemoved != 0 ||
-						event.Flags&fsevents.ItemRenamed != 0 {
-						o.observer.update("/" + event.Path)
+					if event.Flags&(fsevents.ItemCreated|fsevents.ItemModified|fsevents.ItemRemoved|fsevents.ItemRenamed|fsevents.ItemInodeMetaMod) == 0 {
+						continue
 					}
+					changes.add("/" + event.Path)
 				}
 			}
 		}
@@ -0,0 +1,96 @@
+// +build !darwin
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFsnotifyBurst(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-fsnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	observer := &testObserver{ctx: ctx, dir: root, batches: make(chan []string, 10)}
+	newSubject(observer, nil).notify()
+
+	// A burst of changes, as the ones of a git checkout, including a new
+	// directory and the files written in it.
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("a/a%d.go", i)] = "package a\n"
+	}
+	writeTestFiles(t, root, files)
+	writeTestFiles(t, root, map[string]string{"a/a0.go": "package a\n\nfunc F() {}\n"})
+
+	var batch []string
+	select {
+	case batch = <-observer.batches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch of the burst")
+	}
+	seen := make(map[string]bool)
+	for _, event := range batch {
+		if seen[event] {
+			t.Errorf("got %s twice in the batch", event)
+		}
+		seen[event] = true
+	}
+	if !seen[filepath.Join(root, "a")] {
+		t.Errorf("got batch %v, want the new directory", batch)
+	}
+
+	select {
+	case batch := <-observer.batches:
+		t.Errorf("got a second batch %v of the burst", batch)
+	case <-time.After(3 * coalesceDelay):
+	}
+}
+
+func TestWatchOnce(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewProject(ctx, testConn{}, root, nil)
+	p.SetWatchMode(WatchPoll, time.Hour)
+	p.setState(loadState{cached: true})
+	p.SetClientWatcher(true)
+	p.startWatcher()
+	if p.watching {
+		t.Fatal("the internal file watcher is started while the client watches the files")
+	}
+
+	// The client stops watching the files while the project is loaded.
+	p.Watch()
+	p.Watch()
+	close(p.ready)
+	p.startWatcher()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.watchMu.Lock()
+		watching, client := p.watching, p.clientWatcher
+		p.watchMu.Unlock()
+		if watching && !client {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the internal file watcher is not started once the client stops watching the files")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
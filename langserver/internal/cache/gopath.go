@@ -1,9 +1,13 @@
 package cache
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
-
-	"golang.org/x/tools/go/packages"
 )
 
 type gopath struct {
@@ -36,31 +40,128 @@ func (p *gopath) doInit() error {
 	return nil
 }
 
-func (p *gopath) rebuildCache() (bool, error) {
-	err := p.buildCache()
-	return err == nil, err
-}
-
 func (p *gopath) buildCache() error {
 	p.project.view.mu.Lock()
-	defer p.project.view.mu.Unlock()
-
 	cfg := p.project.view.Config
+	p.project.view.mu.Unlock()
+
 	cfg.Dir = p.rootDir
-	cfg.Mode = packages.LoadAllSyntax
 
 	var pattern string
 	if p.underGoroot {
+		if cfg.Env != nil {
+			// The packages of GOROOT are loaded in GOPATH mode, see
+			// createBuiltin.
+			cfg.Env = append(append([]string{}, cfg.Env...), go111module+"=auto")
+		}
 		pattern = cfg.Dir
 	} else {
 		pattern = p.importPath + "/..."
 	}
 
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
+	return p.project.loadCache(cfg, pattern)
+}
+
+// vcsDirs are the directories marking the root of a repository.
+var vcsDirs = []string{".git", ".hg", ".svn", ".bzr"}
+
+// hostingDepths are the numbers of elements of the import paths of the
+// repositories of the well-known hosting sites, such as github.com/user/repo.
+// They tell the repositories without VCS directory, such as the ones
+// downloaded by go get -d from an archive, from the directories above them.
+var hostingDepths = map[string]int{
+	"github.com":    3,
+	"bitbucket.org": 3,
+	"gitlab.com":    3,
+	"golang.org":    3,
+	"gopkg.in":      2,
+}
+
+// repositorySearchDepth is how deep the repositories are searched below a
+// project without VCS directory.
+const repositorySearchDepth = 4
+
+// SetRootImportPath sets the import path of the root of the repository of the
+// project in GOPATH, instead of finding it from the VCS directories.
+func (p *Project) SetRootImportPath(rootImportPath string) {
+	p.rootImportPath = strings.Trim(rootImportPath, "/")
+}
+
+// repositoryImportPath returns the import path of the repository of the
+// project, whose import path in GOPATH is importPath: the root import path if
+// it is set, else the import path of the nearest directory holding a VCS
+// directory. The project must be the repository or inside it: a project
+// above a repository, which would load every repository below it, is
+// rejected.
+func (p *Project) repositoryImportPath(importPath string) (string, error) {
+	if root := p.rootImportPath; root != "" {
+		switch {
+		case importPath == root || strings.HasPrefix(importPath, root+"/"):
+			return root, nil
+		case strings.HasPrefix(root, importPath+"/"):
+			return "", fmt.Errorf("%s is not correct root dir of project, it is above the root import path %s", p.rootDir, root)
+		}
+		p.notifyLog(fmt.Sprintf("the root import path %s is not the one of %s in GOPATH %s", root, p.rootDir, importPath))
 	}
 
-	p.project.setCache(pkgs)
-	return nil
+	rootDir := filepath.ToSlash(p.rootDir)
+	srcDir := strings.TrimSuffix(rootDir, "/"+importPath)
+	if dir := findRepositoryRoot(rootDir, srcDir); dir != "" {
+		return strings.TrimPrefix(dir, srcDir+"/"), nil
+	}
+
+	// Without VCS directory, the project is the repository unless it is a
+	// hosting site, such as github.com, a user of a well-known hosting site,
+	// such as github.com/user, or a directory holding repositories.
+	elements := strings.Split(importPath, "/")
+	if len(elements) == 1 && strings.Contains(importPath, ".") {
+		return "", fmt.Errorf("%s is not correct root dir of project, it is a hosting site", p.rootDir)
+	}
+	if depth, ok := hostingDepths[elements[0]]; ok && len(elements) < depth {
+		return "", fmt.Errorf("%s is not correct root dir of project, it is above the repositories of %s", p.rootDir, elements[0])
+	}
+	if dir := p.findRepositoryBelow(rootDir, repositorySearchDepth); dir != "" {
+		return "", fmt.Errorf("%s is not correct root dir of project, it is above the repository %s", p.rootDir, strings.TrimPrefix(dir, srcDir+"/"))
+	}
+	return importPath, nil
+}
+
+// findRepositoryRoot returns the nearest directory holding a VCS directory,
+// from dir up to srcDir excluded, or "".
+func findRepositoryRoot(dir, srcDir string) string {
+	for dir != srcDir && strings.HasPrefix(dir, srcDir+"/") {
+		for _, vcsDir := range vcsDirs {
+			if _, err := os.Stat(filepath.Join(dir, vcsDir)); err == nil {
+				return dir
+			}
+		}
+		dir = path.Dir(dir)
+	}
+	return ""
+}
+
+// findRepositoryBelow returns a directory holding a VCS directory below dir,
+// at most depth levels below it, or "". The directories skipped by the walks
+// are not searched.
+func (p *Project) findRepositoryBelow(dir string, depth int) string {
+	if depth == 0 {
+		return ""
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, fi := range infos {
+		sub := path.Join(dir, fi.Name())
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || p.skipDir(sub) {
+			continue
+		}
+		if findRepositoryRoot(sub, dir) != "" {
+			return sub
+		}
+		if found := p.findRepositoryBelow(sub, depth-1); found != "" {
+			return found
+		}
+	}
+	return ""
 }
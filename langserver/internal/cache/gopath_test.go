@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepositoryImportPath(t *testing.T) {
+	src, err := ioutil.TempDir("", "bingo-gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	src = filepath.ToSlash(src)
+
+	for _, dir := range []string{"git.company.com/group/subgroup/repo/.git/refs", "git.company.com/group/subgroup/repo/cmd/tool", "example.com/nogit/pkg", "github.com/user/repo/pkg"} {
+		if err := os.MkdirAll(filepath.Join(src, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		importPath     string
+		rootImportPath string
+		want           string
+		wantErr        bool
+	}{
+		{"git.company.com/group/subgroup/repo", "", "git.company.com/group/subgroup/repo", false},
+		{"git.company.com/group/subgroup/repo/cmd/tool", "", "git.company.com/group/subgroup/repo", false},
+		{"git.company.com/group", "", "", true},
+		{"example.com/nogit/pkg", "", "example.com/nogit/pkg", false},
+		{"example.com", "", "", true},
+		{"github.com/user", "", "", true},
+		{"github.com/user/repo", "", "github.com/user/repo", false},
+		{"example.com/nogit/pkg", "example.com/nogit", "example.com/nogit", false},
+		{"example.com/nogit", "example.com/nogit/pkg", "", true},
+	}
+	for _, test := range tests {
+		p := NewProject(context.Background(), nil, src+"/"+test.importPath, nil)
+		p.SetRootImportPath(test.rootImportPath)
+		got, err := p.repositoryImportPath(test.importPath)
+		if (err != nil) != test.wantErr {
+			t.Errorf("repositoryImportPath(%s) with root %q: got error %v", test.importPath, test.rootImportPath, err)
+		}
+		if got != test.want {
+			t.Errorf("repositoryImportPath(%s) with root %q = %q, want %q", test.importPath, test.rootImportPath, got, test.want)
+		}
+	}
+}
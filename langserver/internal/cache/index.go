@@ -0,0 +1,737 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// indexVersion is bumped whenever the layout of the index changes.
+const indexVersion = 2
+
+// indexEnv are the environment variables of the go command which change the
+// packages loaded, and so the index.
+var indexEnv = []string{
+	"GOOS", "GOARCH", "GOARM", "GO386", "GOMIPS", "GOPATH", "GOROOT", "GO111MODULE", "GOFLAGS",
+	"GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOSUMDB",
+	"CGO_ENABLED", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS",
+}
+
+// index is the on-disk summary of the packages loaded for a module or a
+// GOPATH workspace. Packages whose files are unchanged are restored from their
+// export data on startup instead of being type-checked again, and their
+// symbols and references are served from the index until their syntax is
+// loaded.
+type index struct {
+	Version  int
+	Packages map[string]*indexPackage
+}
+
+type indexPackage struct {
+	ID         string
+	Files      []indexFile
+	Errors     []packages.Error
+	ExportData []byte
+	Symbols    []Symbol
+	References map[string][]token.Position
+}
+
+type indexFile struct {
+	Name    string
+	ModTime time.Time
+	Size    int64
+	Hash    string
+}
+
+// indexDir returns the directory of the persistent indexes, or "" if they are
+// disabled.
+func (p *Project) indexDir() string {
+	if p.indexDirectory != "" {
+		if p.indexDirectory == "none" {
+			return ""
+		}
+		return p.indexDirectory
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bingo", "index")
+}
+
+// SetIndexDir sets the directory of the persistent indexes. "" selects the
+// default directory under the user cache directory, "none" disables them.
+func (p *Project) SetIndexDir(dir string) {
+	p.indexDirectory = dir
+}
+
+// indexFilename returns the file of the index of the packages loaded from
+// pattern in dir with cfg.
+func (p *Project) indexFilename(cfg *packages.Config, pattern string) string {
+	dir := p.indexDir()
+	if dir == "" {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%s\x00", indexVersion, runtime.Version(), build.Default.GOROOT, cfg.Dir, pattern)
+	fmt.Fprintf(h, "%s\x00%s\x00", strings.Join(cfg.BuildFlags, " "), strings.Join(goEnv(cfg.Env), " "))
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil)[:16])+".gob")
+}
+
+// goEnv returns the variables of indexEnv set in env, sorted by name. The
+// last value of a variable is the one the go command sees.
+func goEnv(env []string) []string {
+	values := make(map[string]string)
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 {
+			values[kv[:i]] = kv
+		}
+	}
+
+	var vars []string
+	for _, name := range indexEnv {
+		if kv, ok := values[name]; ok {
+			vars = append(vars, kv)
+		}
+	}
+	return vars
+}
+
+func readIndex(filename string) *index {
+	idx := &index{Version: indexVersion, Packages: make(map[string]*indexPackage)}
+	if filename == "" {
+		return idx
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return idx
+	}
+
+	var stored index
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil || stored.Version != indexVersion {
+		return idx
+	}
+	return &stored
+}
+
+func writeIndex(filename string, idx *index) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", filename, os.Getpid())
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// newIndexPackage returns the index entry of lpkg, type-checked from source.
+func newIndexPackage(cfg *packages.Config, lpkg *packages.Package, stamps []indexFile) (*indexPackage, error) {
+	var buf bytes.Buffer
+	if err := gcexportdata.Write(&buf, cfg.Fset, lpkg.Types); err != nil {
+		return nil, err
+	}
+	sum := newSummary(cfg.Fset, lpkg.Types, lpkg.TypesInfo, nil)
+	return &indexPackage{
+		ID:         lpkg.ID,
+		Files:      stamps,
+		Errors:     lpkg.Errors,
+		ExportData: buf.Bytes(),
+		Symbols:    sum.symbols,
+		References: sum.references,
+	}, nil
+}
+
+// restore returns the entry of a package restored with the new stamps of
+// its files.
+func (e *indexPackage) restore(stamps []indexFile) *indexPackage {
+	restored := *e
+	restored.Files = stamps
+	return &restored
+}
+
+// summary returns the summary of the package stored in the entry, or nil.
+func (e *indexPackage) summary() *summary {
+	if e == nil || e.References == nil {
+		return nil
+	}
+	return &summary{symbols: e.Symbols, references: e.References}
+}
+
+// stampFiles returns the stamps of filenames. The content of a file is only
+// hashed when its modification time or size differs from the stamp in old.
+func stampFiles(filenames []string, old []indexFile) ([]indexFile, bool) {
+	previous := make(map[string]indexFile, len(old))
+	for _, f := range old {
+		previous[f.Name] = f
+	}
+
+	unchanged := len(filenames) == len(old)
+	stamps := make([]indexFile, 0, len(filenames))
+	for _, filename := range filenames {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return nil, false
+		}
+
+		stamp := indexFile{Name: filename, ModTime: fi.ModTime(), Size: fi.Size()}
+		prev, ok := previous[filename]
+		if ok && prev.ModTime.Equal(stamp.ModTime) && prev.Size == stamp.Size {
+			stamp.Hash = prev.Hash
+		} else {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, false
+			}
+			sum := sha256.Sum256(data)
+			stamp.Hash = hex.EncodeToString(sum[:])
+			if !ok || prev.Hash != stamp.Hash {
+				unchanged = false
+			}
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps, unchanged
+}
+
+// loadCache loads the packages matching pattern into the new global cache.
+// The package graph is listed by the go command, and the packages outside of
+// the walked directories are dropped. Then the dependencies already loaded
+// by another project of the workspace are shared, the packages whose files
+// and dependencies are unchanged since the last run are restored from the
+// persistent index, the dependencies outside of the project are read from
+// their compiled export data in DependencyExport mode, and only the other
+// packages are type-checked. The index is written back in the background.
+func (p *Project) loadCache(cfg packages.Config, pattern string) error {
+	filename := p.indexFilename(&cfg, pattern)
+
+	loading := p.getLoading()
+	loading.beginRoot(cfg.Dir)
+	cfg.Context = loading.context(cfg.Context)
+	cfg.Mode = packages.LoadImports
+	pkgs, err := packages.Load(&cfg, pattern)
+	if err != nil {
+		return err
+	}
+	roots := p.loadableRoots(pkgs)
+
+	old := readIndex(filename)
+	idx := &index{Version: indexVersion, Packages: make(map[string]*indexPackage)}
+	restored := make(map[string]bool)
+	shared := make(map[*packages.Package]bool)
+	var restoredCount, exportedCount, checkedCount, sharedCount int
+
+	order := postOrder(roots)
+	var exports map[string]string
+	if p.dependencyMode == DependencyExport {
+		exports = p.exportFiles(cfg.Context, &cfg, order)
+	}
+
+	sizes := configSizes(&cfg)
+	queue := p.newLoadQueue(order)
+	for i := range order {
+		if err := cfg.Context.Err(); err != nil {
+			return err
+		}
+		loading.report(i, len(order))
+		lpkg := queue.next()
+		if lpkg == nil {
+			break
+		}
+
+		lpkg.Fset = cfg.Fset
+		files := lpkg.CompiledGoFiles
+		if len(files) == 0 {
+			files = lpkg.GoFiles
+		}
+
+		entry := old.Packages[lpkg.ID]
+		var stamps []indexFile
+		unchanged := false
+		if filename != "" {
+			var oldFiles []indexFile
+			if entry != nil {
+				oldFiles = entry.Files
+			}
+			stamps, unchanged = stampFiles(files, oldFiles)
+		}
+
+		if dep := p.deps.get(lpkg, shared); dep != nil && p.isDependency(lpkg) {
+			dep.share(lpkg)
+			shared[lpkg] = true
+			restored[lpkg.ID] = true
+			sharedCount++
+			var sum *summary
+			if unchanged && entry != nil {
+				idx.Packages[lpkg.ID] = entry.restore(stamps)
+				sum = entry.summary()
+			}
+			p.addToCache(&cfg, lpkg, sizes, dep.restored, sum)
+			continue
+		}
+
+		fresh := unchanged && entry != nil && len(entry.ExportData) > 0
+		for _, imp := range lpkg.Imports {
+			// unsafe is built into go/types and has no export data.
+			if !restored[imp.ID] && imp.PkgPath != "unsafe" {
+				fresh = false
+			}
+		}
+
+		if fresh {
+			imports := make(map[string]*types.Package)
+			addDependencies(imports, lpkg)
+			typ, err := gcexportdata.Read(bytes.NewReader(entry.ExportData), cfg.Fset, imports, lpkg.PkgPath)
+			if err == nil {
+				lpkg.Types = typ
+				lpkg.Errors = entry.Errors
+				restored[lpkg.ID] = true
+				restoredCount++
+				idx.Packages[lpkg.ID] = entry.restore(stamps)
+				p.shareDependency(lpkg, true, shared)
+				p.addToCache(&cfg, lpkg, sizes, true, entry.summary())
+				continue
+			}
+		}
+
+		if exportFile := exports[lpkg.ID]; exportFile != "" && p.isDependency(lpkg) {
+			imports := make(map[string]*types.Package)
+			addDependencies(imports, lpkg)
+			typ, err := readExportFile(exportFile, cfg.Fset, imports, lpkg.PkgPath)
+			if err == nil {
+				lpkg.Types = typ
+				restored[lpkg.ID] = true
+				exportedCount++
+				p.shareDependency(lpkg, true, shared)
+				p.addToCache(&cfg, lpkg, sizes, true, nil)
+				continue
+			}
+		}
+
+		checkPackage(&cfg, lpkg, files, sizes)
+		checkedCount++
+		p.shareDependency(lpkg, false, shared)
+		p.addToCache(&cfg, lpkg, sizes, false, nil)
+		if stamps == nil || lpkg.IllTyped || lpkg.Types == types.Unsafe {
+			continue
+		}
+		if e, err := newIndexPackage(&cfg, lpkg, stamps); err == nil {
+			idx.Packages[lpkg.ID] = e
+		}
+	}
+
+	loading.report(len(order), len(order))
+	if exportedCount > 0 {
+		p.notifyLog(fmt.Sprintf("load %s: %d dependencies read from export data", cfg.Dir, exportedCount))
+	}
+	if sharedCount > 0 {
+		p.notifyLog(fmt.Sprintf("load %s: %d dependencies shared with other projects", cfg.Dir, sharedCount))
+	}
+	if filename == "" {
+		return nil
+	}
+	p.notifyLog(fmt.Sprintf("index %s: %d packages restored, %d packages type-checked", cfg.Dir, restoredCount, checkedCount))
+
+	go func() {
+		if err := writeIndex(filename, idx); err != nil {
+			p.notifyLog(fmt.Sprintf("failed to write index %s: %v", filename, err))
+		}
+	}()
+	return nil
+}
+
+// addToCache adds lpkg to the new global cache as soon as it is loaded, so
+// that the requests see it before the end of the load. The syntax of the
+// packages restored from their export data is loaded on demand, their
+// symbols and references are served from sum until then.
+func (p *Project) addToCache(cfg *packages.Config, lpkg *packages.Package, sizes types.Sizes, restored bool, sum *summary) {
+	p.newCache.Add(lpkg)
+	if !restored {
+		return
+	}
+
+	p.newCache.RLock()
+	pkg := p.newCache.get(lpkg.ID)
+	p.newCache.RUnlock()
+	if pkg != nil && pkg.types == lpkg.Types {
+		pkg.detailsMu.Lock()
+		pkg.source = newLazySource(pkg, cfg.ParseFile, sizes)
+		pkg.summary = sum
+		pkg.detailsMu.Unlock()
+	}
+}
+
+// shareDependency shares lpkg with the other projects of the workspace, when
+// it is a dependency.
+func (p *Project) shareDependency(lpkg *packages.Package, restored bool, shared map[*packages.Package]bool) {
+	if p.deps != nil && p.isDependency(lpkg) && p.deps.put(lpkg, restored, shared) {
+		shared[lpkg] = true
+	}
+}
+
+// loadableRoots returns the packages of pkgs which are in the directories
+// loaded into the global cache.
+func (p *Project) loadableRoots(pkgs []*packages.Package) []*packages.Package {
+	var roots []*packages.Package
+	for _, lpkg := range pkgs {
+		files := lpkg.CompiledGoFiles
+		if len(files) == 0 {
+			files = lpkg.GoFiles
+		}
+		if len(files) > 0 && !p.loadable(filepath.Dir(files[0])) {
+			continue
+		}
+		roots = append(roots, lpkg)
+	}
+	return roots
+}
+
+// configSizes returns the sizes of the architecture the packages of cfg are
+// built for.
+func configSizes(cfg *packages.Config) types.Sizes {
+	sizes := types.SizesFor("gc", build.Default.GOARCH)
+	for _, env := range cfg.Env {
+		if strings.HasPrefix(env, "GOARCH=") {
+			sizes = types.SizesFor("gc", strings.TrimPrefix(env, "GOARCH="))
+		}
+	}
+	return sizes
+}
+
+// postOrder returns the packages of the import graph of roots, every package
+// after its dependencies.
+func postOrder(roots []*packages.Package) []*packages.Package {
+	var order []*packages.Package
+	seen := make(map[*packages.Package]bool)
+	var visit func(*packages.Package)
+	visit = func(lpkg *packages.Package) {
+		if seen[lpkg] {
+			return
+		}
+		seen[lpkg] = true
+		for _, imp := range lpkg.Imports {
+			visit(imp)
+		}
+		order = append(order, lpkg)
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	return order
+}
+
+// addDependencies adds the types of the transitive dependencies of lpkg to
+// imports, so that its export data refers to the same objects.
+func addDependencies(imports map[string]*types.Package, lpkg *packages.Package) {
+	for _, imp := range lpkg.Imports {
+		if imp.Types == nil {
+			continue
+		}
+		if _, ok := imports[imp.PkgPath]; ok {
+			continue
+		}
+		imports[imp.PkgPath] = imp.Types
+		addDependencies(imports, imp)
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// checkPackage parses and type-checks lpkg, whose dependencies are already
+// type-checked or restored.
+func checkPackage(cfg *packages.Config, lpkg *packages.Package, filenames []string, sizes types.Sizes) {
+	if lpkg.PkgPath == "unsafe" {
+		// Like go/packages, unsafe has no syntax, its types are built in.
+		lpkg.Types = types.Unsafe
+		lpkg.TypesInfo = new(types.Info)
+		return
+	}
+
+	for _, filename := range filenames {
+		// Like go/packages, the overlay replaces the content of the files.
+		src, ok := cfg.Overlay[filename]
+		if !ok {
+			var err error
+			src, err = ioutil.ReadFile(filename)
+			if err != nil {
+				lpkg.Errors = append(lpkg.Errors, packages.Error{Pos: filename, Msg: err.Error(), Kind: packages.ParseError})
+				continue
+			}
+		}
+		f, err := cfg.ParseFile(cfg.Fset, filename, src)
+		if f != nil {
+			lpkg.Syntax = append(lpkg.Syntax, f)
+		}
+		if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+			lpkg.Errors = append(lpkg.Errors, packages.Error{Pos: list[0].Pos.String(), Msg: list[0].Msg, Kind: packages.ParseError})
+		}
+	}
+
+	lpkg.TypesInfo = newTypesInfo()
+	lpkg.Types = types.NewPackage(lpkg.PkgPath, lpkg.Name)
+	tc := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			imp := lpkg.Imports[path]
+			if imp == nil {
+				// The imports of the cached packages are indexed by
+				// package path, which differs for vendored packages.
+				for _, i := range lpkg.Imports {
+					if strings.HasSuffix(i.PkgPath, "vendor/"+path) {
+						imp = i
+					}
+				}
+			}
+			if imp == nil || imp.Types == nil {
+				return nil, fmt.Errorf("no type information for %q", path)
+			}
+			return imp.Types, nil
+		}),
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				lpkg.Errors = append(lpkg.Errors, packages.Error{Pos: cfg.Fset.Position(terr.Pos).String(), Msg: terr.Msg, Kind: packages.TypeError})
+			}
+		},
+		Sizes: sizes,
+	}
+	_ = types.NewChecker(tc, cfg.Fset, lpkg.Types, lpkg.TypesInfo).Files(lpkg.Syntax)
+	lpkg.IllTyped = len(lpkg.Errors) > 0
+}
+
+func newTypesInfo() *types.Info {
+	return &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+}
+
+// lazySource holds the syntax and type information of a package restored
+// from the index or evicted from memory. They are only computed when needed,
+// by type-checking the package again against the types of its dependencies.
+// The types of the package stay those restored or checked first, which its
+// importers refer to: the type information refers to their objects.
+type lazySource struct {
+	mu        sync.Mutex
+	load      func() ([]*ast.File, *types.Info, map[types.Object]token.Pos)
+	loaded    bool
+	syntax    []*ast.File
+	typesInfo *types.Info
+
+	// positions are the declarations in the syntax of the objects of the
+	// types of the package whose positions differ, like the objects read
+	// from export data.
+	positions map[types.Object]token.Pos
+
+	// files are the token files of the syntax before its eviction, see
+	// keepPositions.
+	files []*token.File
+
+	// evicted is set once the source was evicted, the loads which follow
+	// are counted in reloads.
+	evicted bool
+	reloads *int64
+}
+
+func newLazySource(pkg *Package, parseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error), sizes types.Sizes) *lazySource {
+	s := &lazySource{}
+	s.load = func() ([]*ast.File, *types.Info, map[types.Object]token.Pos) {
+		lpkg := &packages.Package{
+			ID:      pkg.id,
+			Name:    pkg.name,
+			PkgPath: pkg.pkgPath,
+			Imports: make(map[string]*packages.Package),
+		}
+		for path, imp := range pkg.imports {
+			lpkg.Imports[path] = &packages.Package{PkgPath: imp.pkgPath, Types: imp.GetTypes()}
+		}
+		cfg := &packages.Config{Fset: pkg.fset, ParseFile: keepPositions(parseFile, s.files)}
+		checkPackage(cfg, lpkg, pkg.files, sizes)
+		return lpkg.Syntax, lpkg.TypesInfo, canonicalize(lpkg.TypesInfo, pkg.types)
+	}
+	return s
+}
+
+func (s *lazySource) get() ([]*ast.File, *types.Info) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureLoaded()
+	return s.syntax, s.typesInfo
+}
+
+func (s *lazySource) ensureLoaded() {
+	if !s.loaded {
+		s.syntax, s.typesInfo, s.positions = s.load()
+		s.loaded = true
+		if s.evicted && s.reloads != nil {
+			atomic.AddInt64(s.reloads, 1)
+		}
+	}
+}
+
+// objectPos returns the declaration of obj in the syntax, if it differs
+// from the position of obj.
+func (s *lazySource) objectPos(obj types.Object) (token.Pos, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ensureLoaded()
+	pos, ok := s.positions[obj]
+	return pos, ok
+}
+
+func (s *lazySource) isLoaded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loaded
+}
+
+// evict drops the loaded syntax and type information, and returns the
+// dropped type information and positions. The loads which follow are
+// counted in reloads.
+func (s *lazySource) evict(fset *token.FileSet, reloads *int64) (*types.Info, map[types.Object]token.Pos, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evicted = true
+	s.reloads = reloads
+	if !s.loaded {
+		return nil, nil, false
+	}
+	typesInfo, positions := s.typesInfo, s.positions
+	s.files = tokenFiles(fset, s.syntax)
+	s.syntax, s.typesInfo, s.positions = nil, nil, nil
+	s.loaded = false
+	return typesInfo, positions, true
+}
+
+// canonicalize replaces the objects of the package typ in info, checked
+// again from source, by the objects of typ, which the importers refer to.
+// It returns the declarations in the syntax of the objects of typ whose
+// positions differ.
+func canonicalize(info *types.Info, typ *types.Package) map[types.Object]token.Pos {
+	positions := make(map[types.Object]token.Pos)
+	canonical := make(map[types.Object]types.Object)
+	lookup := func(obj types.Object) types.Object {
+		if obj == nil || obj.Pkg() == nil || obj.Pkg() == typ || obj.Pkg().Path() != typ.Path() {
+			return obj
+		}
+		c, ok := canonical[obj]
+		if !ok {
+			if path, err := objectpath.For(obj); err == nil {
+				c, _ = objectpath.Object(typ, path)
+			}
+			canonical[obj] = c
+			if c != nil && c.Pos() != obj.Pos() {
+				positions[c] = obj.Pos()
+			}
+		}
+		if c == nil {
+			// A local object, or one which is not in the types any more.
+			return obj
+		}
+		return c
+	}
+
+	for ident, obj := range info.Defs {
+		info.Defs[ident] = lookup(obj)
+	}
+	for ident, obj := range info.Uses {
+		info.Uses[ident] = lookup(obj)
+	}
+	for node, obj := range info.Implicits {
+		info.Implicits[node] = lookup(obj)
+	}
+	return positions
+}
+
+func tokenFiles(fset *token.FileSet, syntax []*ast.File) []*token.File {
+	var files []*token.File
+	for _, f := range syntax {
+		if tf := fset.File(f.Pos()); tf != nil {
+			files = append(files, tf)
+		}
+	}
+	return files
+}
+
+// keepPositions returns a parse function which parses the files of files at
+// their previous positions, so that the objects checked against the previous
+// syntax still point into the syntax parsed again, and no token file is added
+// to the file set. The files whose lines changed since are parsed at new
+// positions.
+func keepPositions(parseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error), files []*token.File) func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	if len(files) == 0 {
+		return parseFile
+	}
+
+	previous := make(map[string]*token.File, len(files))
+	for _, f := range files {
+		previous[f.Name()] = f
+	}
+	return func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		if f := previous[filename]; f != nil && f.Size() == len(src) {
+			// A placeholder file moves the base of a private file set
+			// to the previous one.
+			private := token.NewFileSet()
+			if f.Base() > private.Base() {
+				private.AddFile("", -1, f.Base()-private.Base()-1)
+			}
+			file, err := parseFile(private, filename, src)
+			if file != nil && sameLines(f, private.File(file.Pos())) {
+				return file, err
+			}
+		}
+		return parseFile(fset, filename, src)
+	}
+}
+
+// sameLines reports whether the token files x and y, at the same base, have
+// the same lines.
+func sameLines(x, y *token.File) bool {
+	if y == nil || x.Base() != y.Base() || x.LineCount() != y.LineCount() {
+		return false
+	}
+	for line := 1; line <= x.LineCount(); line++ {
+		if x.LineStart(line) != y.LineStart(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"context"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/tools/go/packages"
+)
+
+// testConn drops the notifications of the projects.
+type testConn struct{}
+
+func (testConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	return nil
+}
+
+func (testConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	return nil
+}
+
+func (testConn) Close() error { return nil }
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIndexFilename(t *testing.T) {
+	p := NewProject(context.Background(), nil, "/ws", nil)
+	p.SetIndexDir("/index")
+	filename := func(env ...string) string {
+		return p.indexFilename(&packages.Config{Dir: "/ws", Env: env}, "/ws/...")
+	}
+
+	base := filename("GOFLAGS=-mod=mod", "GOOS=linux")
+	tests := []struct {
+		env  []string
+		same bool
+	}{
+		{[]string{"GOOS=linux", "GOFLAGS=-mod=mod"}, true},
+		{[]string{"GOFLAGS=-mod=mod", "GOOS=linux", "TERM=xterm", "PWD=/tmp"}, true},
+		{[]string{"GOFLAGS=-mod=vendor", "GOOS=linux"}, false},
+		{[]string{"GOFLAGS=-mod=mod", "GOOS=linux", "GOOS=windows"}, false},
+		{[]string{"GOFLAGS=-mod=mod", "GOOS=linux", "CGO_ENABLED=0"}, false},
+	}
+	for _, test := range tests {
+		if got := filename(test.env...); (got == base) != test.same {
+			t.Errorf("index of %v: got %s, want the same index %v", test.env, got, test.same)
+		}
+	}
+}
+
+func TestStampFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-stamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "a.go")
+	writeTestFiles(t, dir, map[string]string{"a.go": "package a\n\nfunc F() {}\n"})
+	stamps, _ := stampFiles([]string{filename}, nil)
+	if _, unchanged := stampFiles([]string{filename}, stamps); !unchanged {
+		t.Error("an untouched file is stale")
+	}
+
+	// Touching a file doesn't make it stale, changing its content without
+	// changing its size does.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, unchanged := stampFiles([]string{filename}, stamps); !unchanged {
+		t.Error("a touched file is stale")
+	}
+	writeTestFiles(t, dir, map[string]string{"a.go": "package a\n\nfunc G() {}\n"})
+	if err := os.Chtimes(filename, later.Add(time.Hour), later.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, unchanged := stampFiles([]string{filename}, stamps); unchanged {
+		t.Error("a changed file of the same size is not stale")
+	}
+	if _, unchanged := stampFiles([]string{filename, filepath.Join(dir, "b.go")}, stamps); unchanged {
+		t.Error("a missing file is not stale")
+	}
+}
+
+func TestIndexRestore(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	indexDir := filepath.Join(root, "index")
+
+	writeTestFiles(t, root, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\n// F is a function.\nfunc F() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar _ = a.F\n",
+	})
+
+	// load loads the module into a new global cache, and waits for its
+	// index to be written.
+	load := func() *GlobalCache {
+		p := NewProject(context.Background(), testConn{}, root, nil)
+		p.SetIndexDir(indexDir)
+		p.newCache = NewCache()
+		cfg := p.view.Config
+		cfg.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=-mod=mod")
+		filename := p.indexFilename(&cfg, root+"/...")
+		previous, _ := os.Stat(filename)
+		if err := p.loadCache(cfg, root+"/..."); err != nil {
+			t.Fatal(err)
+		}
+		// The index is replaced by another file.
+		for i := 0; ; i++ {
+			if fi, err := os.Stat(filename); err == nil && (previous == nil || !os.SameFile(fi, previous)) {
+				break
+			}
+			if i == 100 {
+				t.Fatal("the index was not written")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return p.newCache
+	}
+	get := func(c *GlobalCache, id string) *Package {
+		c.RLock()
+		defer c.RUnlock()
+		pkg := c.get(id)
+		if pkg == nil {
+			t.Fatalf("no package %s", id)
+		}
+		return pkg
+	}
+
+	load()
+	c := load()
+	a, b := get(c, "example.com/m/a"), get(c, "example.com/m/b")
+	if a.loaded() || b.loaded() {
+		t.Fatal("the unchanged packages are not restored from the index")
+	}
+
+	symbols, ok := a.GetSymbols()
+	if !ok || len(symbols) != 1 || symbols[0].Name != "F" || symbols[0].Position.Line != 4 || symbols[0].Position.Column != 6 {
+		t.Errorf("got symbols %v, want F at 4:6", symbols)
+	}
+	f := a.GetTypes().Scope().Lookup("F")
+	refs, ok := b.GetReferences(f)
+	if !ok || len(refs) != 1 || !strings.HasSuffix(refs[0].Filename, "b.go") || refs[0].Line != 5 || refs[0].Column != 11 {
+		t.Errorf("got references %v, want b.go:5:11", refs)
+	}
+
+	// The type information loaded on demand refers to the restored types,
+	// and the objects are found in the syntax.
+	for ident, obj := range b.GetTypesInfo().Uses {
+		if ident.Name == "F" && obj != f {
+			t.Errorf("the use of F in b refers to %v, not to the types of a", obj)
+		}
+	}
+	var def types.Object
+	var defPos token.Pos
+	for ident, obj := range a.GetTypesInfo().Defs {
+		if ident.Name == "F" {
+			def, defPos = obj, ident.Pos()
+		}
+	}
+	if def != f {
+		t.Errorf("F is declared as %v, not as the object of the types of a", def)
+	}
+	if pos := a.GetObjectPos(f); pos != defPos {
+		t.Errorf("got F at %s, want %s", a.fset.Position(pos), a.fset.Position(defPos))
+	}
+	if _, ok := a.GetSymbols(); ok {
+		t.Error("got the summary of a loaded package")
+	}
+
+	// A file changed without changing its size makes its package and its
+	// importers stale.
+	writeTestFiles(t, root, map[string]string{"a/a.go": "package a\n\n// F is a function.\nfunc G() {}\n"})
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "a", "a.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	c = load()
+	a, b = get(c, "example.com/m/a"), get(c, "example.com/m/b")
+	if !a.loaded() || !b.loaded() {
+		t.Fatal("the stale packages are restored from the index")
+	}
+	if a.GetTypes().Scope().Lookup("G") == nil {
+		t.Error("the stale package is not type-checked again")
+	}
+	if errs := b.GetErrors(); len(errs) != 1 || !strings.Contains(errs[0].Msg, "F") {
+		t.Errorf("got errors %v for the importer, want the undeclared a.F", errs)
+	}
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// memoryCheckInterval is how often the heap size is compared with the memory
+// limit.
+const memoryCheckInterval = 10 * time.Second
+
+// trimTarget is the fraction of the memory limit the heap is brought back to
+// by an eviction, so that the packages are not evicted again right away.
+const trimTarget = 0.8
+
+// detailsCost estimates the memory used by the syntax and type information of
+// a package, per byte of its source files. It is measured by loading fmt,
+// go/types, net/http and bingo with the syntax of their packages, and
+// comparing the heap before and after dropping their syntax and type
+// information: 18 to 20 bytes per byte of source.
+const detailsCost = 20
+
+// accessClock orders the requests for the details of the packages.
+var accessClock int64
+
+// CacheStats reports the memory use of the global cache.
+type CacheStats struct {
+	// Packages is the number of cached packages.
+	Packages int `json:"packages"`
+	// Loaded is the number of cached packages whose syntax and type
+	// information are in memory.
+	Loaded int `json:"loaded"`
+	// Evictions is the number of packages evicted from memory so far.
+	Evictions int64 `json:"evictions"`
+	// Reloads is the number of evicted packages loaded again so far.
+	Reloads int64 `json:"reloads"`
+	// HeapAlloc is the size of the allocated heap objects, in bytes.
+	HeapAlloc uint64 `json:"heapAlloc"`
+	// MemoryLimit is the heap size above which packages are evicted, in
+	// bytes. 0 if unlimited.
+	MemoryLimit uint64 `json:"memoryLimit"`
+}
+
+// SetMemoryLimit sets the heap size, in bytes, above which the syntax and
+// type information of the least recently used packages of the global cache
+// are evicted. They are loaded again when a request needs them, while their
+// types stay in memory. 0 disables the limit.
+func (p *Project) SetMemoryLimit(limit uint64) {
+	p.memoryMu.Lock()
+	defer p.memoryMu.Unlock()
+	p.memoryLimit = limit
+}
+
+func (p *Project) getMemoryLimit() uint64 {
+	p.memoryMu.Lock()
+	defer p.memoryMu.Unlock()
+	return p.memoryLimit
+}
+
+// CacheStats returns the statistics of the global cache.
+func (p *Project) CacheStats() CacheStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	stats := CacheStats{HeapAlloc: ms.HeapAlloc, MemoryLimit: p.getMemoryLimit()}
+	cache := p.getCache()
+	if cache == nil {
+		return stats
+	}
+
+	stats.Evictions = atomic.LoadInt64(&cache.evictions)
+	stats.Reloads = atomic.LoadInt64(&cache.reloads)
+	cache.RLock()
+	defer cache.RUnlock()
+	for _, gp := range cache.idMap {
+		stats.Packages++
+		if gp.pkg.loaded() {
+			stats.Loaded++
+		}
+	}
+	return stats
+}
+
+// watchMemory checks the heap size against the memory limit until the
+// project is closed.
+func (p *Project) watchMemory() {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.context.Done():
+			return
+		case <-ticker.C:
+			p.trimCache()
+		}
+	}
+}
+
+// trimCache evicts the least recently used packages of the global cache when
+// the heap is larger than the memory limit.
+func (p *Project) trimCache() {
+	limit := p.getMemoryLimit()
+	cache := p.getCache()
+	if limit == 0 || cache == nil {
+		return
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapAlloc <= limit {
+		return
+	}
+
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	v.mu.Unlock()
+
+	evicted := cache.evict(ms.HeapAlloc-uint64(float64(limit)*trimTarget), cfg.ParseFile, configSizes(&cfg))
+	if evicted == 0 {
+		return
+	}
+
+	debug.FreeOSMemory()
+	runtime.ReadMemStats(&ms)
+	p.notifyLog(fmt.Sprintf("evict %d packages, heap %d MB, limit %d MB", evicted, ms.HeapAlloc>>20, limit>>20))
+}
+
+// evict evicts the least recently used packages until the estimated size of
+// their details reaches size, and returns the number of evicted packages.
+func (c *GlobalCache) evict(size uint64, parseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error), sizes types.Sizes) int {
+	type candidate struct {
+		pkg      *Package
+		lastUsed int64
+	}
+
+	c.RLock()
+	var candidates []candidate
+	for _, gp := range c.idMap {
+		if len(gp.pkg.files) > 0 && gp.pkg.loaded() {
+			candidates = append(candidates, candidate{gp.pkg, atomic.LoadInt64(gp.pkg.lastUsed)})
+		}
+	}
+	c.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed < candidates[j].lastUsed
+	})
+
+	var freed uint64
+	var evicted int
+	for _, candidate := range candidates {
+		if freed >= size {
+			break
+		}
+		if candidate.pkg.evict(parseFile, sizes, &c.reloads) {
+			freed += candidate.pkg.detailsSize()
+			evicted++
+		}
+	}
+
+	atomic.AddInt64(&c.evictions, int64(evicted))
+	return evicted
+}
+
+// loaded reports whether the syntax and type information of the package are
+// in memory.
+func (pkg *Package) loaded() bool {
+	pkg.detailsMu.Lock()
+	s, syntax := pkg.source, pkg.syntax
+	pkg.detailsMu.Unlock()
+
+	if s != nil {
+		return s.isLoaded()
+	}
+	return syntax != nil
+}
+
+// evict drops the syntax and type information of the package, and the
+// results of its analyses. Its types stay in memory for its importers, its
+// summary for the symbol and reference requests. It reports whether anything
+// was dropped.
+func (pkg *Package) evict(parseFile func(fset *token.FileSet, filename string, src []byte) (*ast.File, error), sizes types.Sizes, reloads *int64) bool {
+	pkg.detailsMu.Lock()
+	evicted := false
+	if pkg.source != nil {
+		var typesInfo *types.Info
+		var positions map[types.Object]token.Pos
+		typesInfo, positions, evicted = pkg.source.evict(pkg.fset, reloads)
+		if evicted && pkg.summary == nil {
+			pkg.summary = newSummary(pkg.fset, pkg.types, typesInfo, positions)
+		}
+	} else if pkg.types != nil && pkg.syntax != nil {
+		pkg.summary = newSummary(pkg.fset, pkg.types, pkg.typesInfo, nil)
+		s := newLazySource(pkg, parseFile, sizes)
+		s.files = tokenFiles(pkg.fset, pkg.syntax)
+		s.evicted = true
+		s.reloads = reloads
+		pkg.source = s
+		pkg.syntax, pkg.typesInfo = nil, nil
+		evicted = true
+	}
+	pkg.detailsMu.Unlock()
+
+	if evicted {
+		// The results of the analyses refer to the evicted syntax and
+		// type information.
+		pkg.mu.Lock()
+		pkg.analyses = make(map[*analysis.Analyzer]*analysisEntry)
+		pkg.mu.Unlock()
+	}
+	return evicted
+}
+
+// detailsSize estimates the memory used by the syntax and type information
+// of the package.
+func (pkg *Package) detailsSize() uint64 {
+	var size uint64
+	for _, filename := range pkg.files {
+		if fi, err := os.Stat(filename); err == nil {
+			size += uint64(fi.Size())
+		}
+	}
+	return size * detailsCost
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestEvict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bingo-evict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(filename, []byte("package a\n\n// T is a type.\ntype T struct{ X int }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &packages.Config{
+		Fset: token.NewFileSet(),
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.ParseComments)
+		},
+	}
+	sizes := types.SizesFor("gc", "amd64")
+	lpkg := &packages.Package{ID: "a", Name: "a", PkgPath: "a", CompiledGoFiles: []string{filename}, Fset: cfg.Fset}
+	checkPackage(cfg, lpkg, lpkg.CompiledGoFiles, sizes)
+
+	c := NewCache()
+	c.Add(lpkg)
+	pkg := c.get("a")
+	typ := pkg.GetTypes()
+	obj := typ.Scope().Lookup("T")
+	files := countFiles(cfg.Fset)
+
+	for i := 1; i <= 2; i++ {
+		if n := c.evict(1, cfg.ParseFile, sizes); n != 1 {
+			t.Fatalf("got %d evicted packages, want 1", n)
+		}
+		if pkg.loaded() {
+			t.Fatal("syntax still loaded after the eviction")
+		}
+		if symbols, ok := pkg.GetSymbols(); !ok || len(symbols) != 2 {
+			t.Fatalf("got symbols %v for the evicted package, want T and X", symbols)
+		}
+
+		// The type information loaded again refers to the objects of the
+		// types, which the importers refer to, and its syntax is at their
+		// positions.
+		var def types.Object
+		var pos token.Pos
+		for ident, o := range pkg.GetTypesInfo().Defs {
+			if ident.Name == "T" {
+				def, pos = o, ident.Pos()
+			}
+		}
+		if pkg.GetTypes() != typ || def != obj {
+			t.Errorf("got T %v after the reload, want the object of the previous types", def)
+		}
+		if pos != obj.Pos() {
+			t.Errorf("got T at %s after the reload, want %s", cfg.Fset.Position(pos), cfg.Fset.Position(obj.Pos()))
+		}
+		if n := countFiles(cfg.Fset); n != files {
+			t.Errorf("got %d files in the file set after the reload, want %d", n, files)
+		}
+		if _, ok := pkg.GetSymbols(); ok {
+			t.Error("got the summary of a loaded package")
+		}
+		if c.evictions != int64(i) || c.reloads != int64(i) {
+			t.Errorf("got %d evictions and %d reloads, want %d and %d", c.evictions, c.reloads, i, i)
+		}
+	}
+}
+
+func TestKeepPositions(t *testing.T) {
+	parseFile := func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		return parser.ParseFile(fset, filename, src, 0)
+	}
+	fset := token.NewFileSet()
+	fset.AddFile("other.go", -1, 100)
+	f, err := parseFile(fset, "a.go", []byte("package a\n\nvar X = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := keepPositions(parseFile, tokenFiles(fset, []*ast.File{f}))
+
+	tests := []struct {
+		src  string
+		keep bool
+	}{
+		{"package a\n\nvar X = 1\n", true},
+		{"package a\n\nvar Y = 2\n", true},
+		// The same size, with other lines.
+		{"package a\nvar X =\n 1\n", false},
+		{"package a\n\nvar X = 10\n", false},
+	}
+	for _, test := range tests {
+		files := countFiles(fset)
+		g, err := parse(fset, "a.go", []byte(test.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keep := g.Pos() == f.Pos(); keep != test.keep {
+			t.Errorf("%q parsed at the previous positions: %v, want %v", test.src, keep, test.keep)
+		}
+		if added := countFiles(fset) - files; test.keep && added != 0 {
+			t.Errorf("%q: %d files added to the file set", test.src, added)
+		}
+		// The declaration is positioned on its line.
+		decl := g.Decls[0].(*ast.GenDecl)
+		if got, want := fset.Position(decl.Pos()).Line, strings.Count(test.src[:strings.Index(test.src, "var")], "\n")+1; got != want {
+			t.Errorf("%q: got the declaration on line %d, want %d", test.src, got, want)
+		}
+	}
+}
+
+func countFiles(fset *token.FileSet) int {
+	n := 0
+	fset.Iterate(func(*token.File) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestSetMemoryLimitWhileTrimming(t *testing.T) {
+	p := NewProject(context.Background(), testConn{}, "/ws", nil)
+	p.setGlobalCache(NewCache())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			p.trimCache()
+			p.CacheStats()
+		}
+	}()
+	for i := uint64(0); i < 100; i++ {
+		p.SetMemoryLimit(i << 40)
+	}
+	<-done
+
+	if got := p.CacheStats().MemoryLimit; got != 99<<40 {
+		t.Errorf("got memory limit %d, want the last one set %d", got, uint64(99)<<40)
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"time"
 
 	"github.com/saibing/bingo/langserver/internal/util"
-
-	"golang.org/x/tools/go/packages"
 )
 
 type moduleInfo struct {
@@ -28,6 +26,15 @@ type module struct {
 	rootDir        string
 	mainModulePath string
 	moduleMap      map[string]moduleInfo
+
+	// tags are the build tags of the module, when hasTags is set, see
+	// SetModuleTags.
+	tags    []string
+	hasTags bool
+
+	// vendored is set when the packages of the module are loaded from its
+	// vendor directory, see vendorEnabled.
+	vendored bool
 }
 
 func newModule(gc *Project, rootDir string) *module {
@@ -44,6 +51,7 @@ func (m *module) init() (err error) {
 }
 
 func (m *module) doInit() error {
+	m.vendored = m.project.vendorEnabled(m.rootDir, m.project.buildFlags())
 	moduleMap, err := m.readGoModule()
 	if err != nil {
 		return err
@@ -54,7 +62,13 @@ func (m *module) doInit() error {
 }
 
 func (m *module) readGoModule() (map[string]moduleInfo, error) {
-	buf, err := invokeGo(context.Background(), m.rootDir, "list", "-m", "-json", "all")
+	args := []string{"list", "-m", "-json", "all"}
+	if m.vendored {
+		// The go command can't list every module from the vendor
+		// directory, the vendored modules are read from vendor/modules.txt.
+		args = []string{"list", "-m", "-json"}
+	}
+	buf, err := m.project.invokeGo(context.Background(), m.rootDir, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +97,15 @@ func (m *module) readGoModule() (map[string]moduleInfo, error) {
 		moduleMap[util.LowerDriver(module.Dir)] = module
 	}
 
+	if m.vendored {
+		vendored, err := readVendoredModules(m.rootDir)
+		if err != nil {
+			return nil, err
+		}
+		for dir, module := range vendored {
+			moduleMap[dir] = module
+		}
+	}
 	return moduleMap, nil
 }
 
@@ -99,6 +122,7 @@ func (m *module) initModule(moduleMap map[string]moduleInfo) {
 }
 
 func (m *module) checkModuleCache() (bool, error) {
+	m.vendored = m.project.vendorEnabled(m.rootDir, m.project.buildFlags())
 	moduleMap, err := m.readGoModule()
 	if err != nil {
 		return false, err
@@ -112,20 +136,6 @@ func (m *module) checkModuleCache() (bool, error) {
 	return true, nil
 }
 
-func (m *module) rebuildCache() (bool, error) {
-	rebuild, err := m.checkModuleCache()
-	if err != nil {
-		return false, err
-	}
-
-	if !rebuild {
-		return false, nil
-	}
-
-	err = m.buildCache()
-	return err == nil, err
-}
-
 func (m *module) hasChanged(moduleMap map[string]moduleInfo) bool {
 	for dir := range moduleMap {
 		// there are some new module add into go.mod
@@ -139,18 +149,15 @@ func (m *module) hasChanged(moduleMap map[string]moduleInfo) bool {
 
 func (m *module) buildCache() error {
 	m.project.view.mu.Lock()
-	defer m.project.view.mu.Unlock()
-
 	cfg := m.project.view.Config
-	cfg.Dir = m.rootDir
-	cfg.Mode = packages.LoadAllSyntax
-	pattern := cfg.Dir + "/..."
+	m.project.view.mu.Unlock()
 
-	pkgs, err := packages.Load(&cfg, pattern)
-	if err != nil {
-		return err
+	if m.project.moduleTags != nil {
+		m.tags, m.hasTags = m.project.moduleTags(m.rootDir)
 	}
+	cfg.Dir = m.rootDir
+	cfg.BuildFlags = m.project.moduleFlags(m, cfg.BuildFlags)
+	pattern := cfg.Dir + "/..."
 
-	m.project.setCache(pkgs)
-	return nil
+	return m.project.loadCache(cfg, pattern)
 }
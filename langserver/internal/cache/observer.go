@@ -2,11 +2,18 @@ package cache
 
 import (
 	"context"
+	"sync"
+	"time"
 )
 
+// coalesceDelay is the quiet period after which a burst of file events, such
+// as the ones of a git checkout, is handled as a single batch.
+const coalesceDelay = 300 * time.Millisecond
+
 type Observer interface {
-	update(event string)
+	update(events []string)
 	root() string
+	skipDir(dir string) bool
 	notifyLog(message string)
 	notifyError(message string)
 	getContext() context.Context
@@ -14,4 +21,58 @@ type Observer interface {
 
 type Subject interface {
 	notify()
-}
\ No newline at end of file
+}
+
+// batcher coalesces the file events of a burst into a single batch, which is
+// handed to apply once no event came for the delay. The batches are applied
+// one at a time, in the order of their events.
+type batcher struct {
+	delay time.Duration
+	apply func(events []string)
+
+	mu      sync.Mutex
+	pending []string
+	seen    map[string]bool
+	timer   *time.Timer
+
+	// applyMu serializes the batches.
+	applyMu sync.Mutex
+}
+
+func newBatcher(delay time.Duration, apply func(events []string)) *batcher {
+	return &batcher{delay: delay, apply: apply, seen: make(map[string]bool)}
+}
+
+// add adds the events to the pending batch, and postpones it.
+func (b *batcher) add(events ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		if !b.seen[event] {
+			b.seen[event] = true
+			b.pending = append(b.pending, event)
+		}
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.delay, b.flush)
+	} else {
+		b.timer.Reset(b.delay)
+	}
+}
+
+// flush applies the pending batch now.
+func (b *batcher) flush() {
+	b.applyMu.Lock()
+	defer b.applyMu.Unlock()
+
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.seen = make(map[string]bool)
+	b.mu.Unlock()
+
+	if len(events) > 0 {
+		b.apply(events)
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testObserver records the batches of the file events of root.
+type testObserver struct {
+	ctx     context.Context
+	dir     string
+	batches chan []string
+}
+
+func (o *testObserver) update(events []string)      { o.batches <- events }
+func (o *testObserver) root() string                { return o.dir }
+func (o *testObserver) skipDir(dir string) bool     { return false }
+func (o *testObserver) notifyLog(message string)    {}
+func (o *testObserver) notifyError(message string)  {}
+func (o *testObserver) getContext() context.Context { return o.ctx }
+
+func TestBatcherOrder(t *testing.T) {
+	var batches [][]string
+	b := newBatcher(time.Hour, func(events []string) {
+		batches = append(batches, events)
+	})
+	b.add("a", "b")
+	b.add("a", "c")
+	b.flush()
+	b.add("b")
+	b.flush()
+	b.flush()
+
+	want := [][]string{{"a", "b", "c"}, {"b"}}
+	if fmt.Sprint(batches) != fmt.Sprint(want) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+}
+
+func TestDidChangeFilesBurst(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	p := NewProject(context.Background(), testConn{}, root, nil)
+	p.setState(loadState{cached: true})
+	changed := make(chan string, 10)
+	p.SetConfigHandler(func(filename string) { changed <- filename })
+
+	// The changes reported while the project is loading are applied in
+	// order once it is loaded, each file once.
+	x, y := filepath.Join(root, "x", ".bingo.json"), filepath.Join(root, "y", ".bingo.json")
+	p.DidChangeFiles([]string{x})
+	p.DidChangeFiles([]string{y, x})
+	time.Sleep(2 * coalesceDelay)
+	select {
+	case filename := <-changed:
+		t.Fatalf("got change of %s while loading", filename)
+	default:
+	}
+	close(p.ready)
+
+	for _, want := range []string{x, y} {
+		select {
+		case filename := <-changed:
+			if filename != want {
+				t.Errorf("got change of %s, want %s", filename, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change of %s", want)
+		}
+	}
+	select {
+	case filename := <-changed:
+		t.Errorf("got change of %s twice", filename)
+	case <-time.After(3 * coalesceDelay):
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"go/types"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/saibing/bingo/langserver/internal/source"
 	"golang.org/x/tools/go/analysis"
@@ -25,12 +26,32 @@ type Package struct {
 	typesInfo   *types.Info
 	fset        *token.FileSet
 
+	// source loads syntax and typesInfo on first use, for the packages
+	// restored from the persistent index or evicted from memory. The types
+	// restored from export data, or checked before the eviction, stay in
+	// types, for the importers of the package. summary serves the symbols
+	// and the references of the package until then.
+	//
+	// detailsMu guards syntax, typesInfo, source and summary, which change
+	// when the package is evicted.
+	detailsMu sync.Mutex
+	source    *lazySource
+	summary   *summary
+
+	// lastUsed is the access clock of the last request for the details of
+	// the package, shared with its clones in the views.
+	lastUsed *int64
+
 	// The analysis cache holds analysis information for all the packages in a view.
 	// Each graph node (action) is one unit of analysis.
 	// Edges express package-to-package (vertical) dependencies,
 	// and analysis-to-analysis (horizontal) dependencies.
 	mu       sync.Mutex
 	analyses map[*analysis.Analyzer]*analysisEntry
+
+	// deprecations caches the deprecation notices of the objects of the
+	// package, guarded by mu.
+	deprecations map[types.Object]string
 }
 
 type analysisEntry struct {
@@ -89,6 +110,9 @@ func (pkg *Package) GetActionGraph(ctx context.Context, a *analysis.Analyzer) (*
 			sort.Strings(importPaths) // for determinism
 			for _, importPath := range importPaths {
 				dep := pkg.imports[importPath]
+				if dep == nil {
+					continue
+				}
 				act, err := dep.GetActionGraph(ctx, a)
 				if err != nil {
 					return nil, err
@@ -110,7 +134,8 @@ func (pkg *Package) GetFilenames() []string {
 }
 
 func (pkg *Package) GetSyntax() []*ast.File {
-	return pkg.syntax
+	syntax, _ := pkg.details()
+	return syntax
 }
 
 func (pkg *Package) GetErrors() []packages.Error {
@@ -122,7 +147,77 @@ func (pkg *Package) GetTypes() *types.Package {
 }
 
 func (pkg *Package) GetTypesInfo() *types.Info {
-	return pkg.typesInfo
+	_, typesInfo := pkg.details()
+	return typesInfo
+}
+
+// details returns the syntax and type information of the package, loading
+// them again if they were evicted from memory.
+func (pkg *Package) details() ([]*ast.File, *types.Info) {
+	if pkg.lastUsed != nil {
+		atomic.StoreInt64(pkg.lastUsed, atomic.AddInt64(&accessClock, 1))
+	}
+
+	pkg.detailsMu.Lock()
+	s, syntax, typesInfo := pkg.source, pkg.syntax, pkg.typesInfo
+	pkg.detailsMu.Unlock()
+
+	if s != nil {
+		return s.get()
+	}
+	return syntax, typesInfo
+}
+
+func (pkg *Package) GetObjectPos(o types.Object) token.Pos {
+	pkg.detailsMu.Lock()
+	s := pkg.source
+	pkg.detailsMu.Unlock()
+
+	if s != nil {
+		if pos, ok := s.objectPos(o); ok {
+			return pos
+		}
+	}
+	return o.Pos()
+}
+
+// GetSymbols returns the symbols of the package when its syntax and type
+// information are not in memory. Unlike GetSyntax, it doesn't load them
+// again. Without a summary, the symbols are those of the types, which are
+// only positioned on their line when read from export data.
+func (pkg *Package) GetSymbols() ([]Symbol, bool) {
+	if len(pkg.files) == 0 || pkg.loaded() {
+		return nil, false
+	}
+
+	pkg.detailsMu.Lock()
+	sum := pkg.summary
+	pkg.detailsMu.Unlock()
+	if sum != nil {
+		return sum.symbols, true
+	}
+	return packageSymbols(pkg.fset, pkg.types, nil), true
+}
+
+// GetReferences returns the positions of the uses of obj in the package
+// when its syntax and type information are not in memory and its summary
+// is. Unlike GetTypesInfo, it doesn't load them again.
+func (pkg *Package) GetReferences(obj types.Object) ([]token.Position, bool) {
+	if pkg.loaded() {
+		return nil, false
+	}
+
+	pkg.detailsMu.Lock()
+	sum := pkg.summary
+	pkg.detailsMu.Unlock()
+	if sum == nil {
+		return nil, false
+	}
+	key, ok := objectKey(obj)
+	if !ok {
+		return nil, false
+	}
+	return sum.references[key], true
 }
 
 func (pkg *Package) GetPkgPath() string {
@@ -130,7 +225,9 @@ func (pkg *Package) GetPkgPath() string {
 }
 
 func (pkg *Package) IsIllTyped() bool {
-	return pkg.types == nil && pkg.typesInfo == nil
+	pkg.detailsMu.Lock()
+	defer pkg.detailsMu.Unlock()
+	return pkg.types == nil && pkg.typesInfo == nil && pkg.source == nil
 }
 
 func (pkg *Package) GetImport(pkgPath string) source.Package {
@@ -141,6 +238,24 @@ func (pkg *Package) GetImport(pkgPath string) source.Package {
 	return nil
 }
 
+func (pkg *Package) GetDeprecation(o types.Object, notice func() string) string {
+	pkg.mu.Lock()
+	n, ok := pkg.deprecations[o]
+	pkg.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = notice()
+	pkg.mu.Lock()
+	if pkg.deprecations == nil {
+		pkg.deprecations = make(map[types.Object]string)
+	}
+	pkg.deprecations[o] = n
+	pkg.mu.Unlock()
+	return n
+}
+
 func (pkg *Package) GetFileSet() *token.FileSet {
 	return pkg.fset
 }
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WatchMode selects how the files of the project are watched.
+type WatchMode string
+
+const (
+	// WatchAuto uses the file system notifications, and switches to
+	// polling when the watches are exhausted.
+	WatchAuto WatchMode = "auto"
+	// WatchFsnotify only uses the file system notifications.
+	WatchFsnotify WatchMode = "fsnotify"
+	// WatchPoll polls the modification times and sizes of the files, for
+	// the file systems without notifications such as NFS or sshfs.
+	WatchPoll WatchMode = "poll"
+)
+
+// pollBudget is the fraction of the polling interval which may be spent on
+// scanning directories. Large trees are scanned over several intervals.
+const pollBudget = 0.1
+
+// defaultPollInterval is the polling interval used when none is configured.
+const defaultPollInterval = 2 * time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	dir     bool
+}
+
+// equal reports whether the stamps are the ones of an unchanged entry. The
+// modification times are compared as instants, whatever their location.
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.dir == other.dir && s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+type pollSubject struct {
+	observer Observer
+	interval time.Duration
+
+	// dirs are the directories to scan, in round-robin, from next.
+	dirs      []string
+	scheduled map[string]bool
+	next      int
+
+	// stamps are the stamps of the entries of the scanned directories.
+	stamps map[string]map[string]fileStamp
+}
+
+func newPollSubject(observer Observer, interval time.Duration) Subject {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &pollSubject{observer: observer, interval: interval}
+}
+
+func (s *pollSubject) notify() {
+	s.scheduled = make(map[string]bool)
+	s.stamps = make(map[string]map[string]fileStamp)
+	s.schedule(s.observer.root())
+
+	// The first scan records the initial state of the files.
+	for i := 0; i < len(s.dirs); i++ {
+		s.scan(s.dirs[i], nil)
+	}
+
+	s.observer.notifyLog(fmt.Sprintf("poll %d dirs every %s", len(s.dirs), s.interval))
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		// A burst, such as a git checkout, may be seen by several polls,
+		// the large trees being scanned over several intervals: its changes
+		// are batched until a poll sees no change.
+		changes := newBatcher(s.interval+coalesceDelay, s.observer.update)
+		for {
+			select {
+			case <-s.observer.getContext().Done():
+				return
+			case <-ticker.C:
+				if changed := s.poll(); len(changed) > 0 {
+					changes.add(changed...)
+				}
+			}
+		}
+	}()
+}
+
+// poll scans the directories from where the previous poll stopped, until
+// they have all been scanned or the budget of the interval is spent. It
+// returns the created, changed and removed paths.
+func (s *pollSubject) poll() []string {
+	start := time.Now()
+	budget := time.Duration(float64(s.interval) * pollBudget)
+
+	var changed []string
+	for n := len(s.dirs); n > 0 && len(s.dirs) > 0; n-- {
+		if s.next >= len(s.dirs) {
+			s.next = 0
+		}
+
+		dir := s.dirs[s.next]
+		var ok bool
+		changed, ok = s.scan(dir, changed)
+		if ok {
+			s.next++
+		} else {
+			s.dirs = append(s.dirs[:s.next], s.dirs[s.next+1:]...)
+			delete(s.scheduled, dir)
+		}
+
+		if time.Since(start) >= budget {
+			break
+		}
+	}
+
+	return changed
+}
+
+func (s *pollSubject) schedule(dir string) {
+	if s.scheduled[dir] {
+		return
+	}
+	s.scheduled[dir] = true
+	s.dirs = append(s.dirs, dir)
+}
+
+// scan compares the entries of dir with their stamps of the previous scan,
+// and appends the changed paths to changed. New subdirectories are scheduled
+// for scanning. It reports whether dir still exists.
+func (s *pollSubject) scan(dir string, changed []string) ([]string, bool) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		// The removal of the directory is reported by its parent.
+		delete(s.stamps, dir)
+		return changed, false
+	}
+
+	old, scanned := s.stamps[dir]
+	stamps := make(map[string]fileStamp, len(files))
+	for _, fi := range files {
+		name := fi.Name()
+		path := filepath.Join(dir, name)
+		var stamp fileStamp
+		if fi.IsDir() {
+			if s.observer.skipDir(path) {
+				continue
+			}
+			stamp = fileStamp{dir: true}
+			s.schedule(path)
+		} else if isWatchedFile(name) {
+			stamp = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		} else {
+			continue
+		}
+
+		stamps[name] = stamp
+		if prev, ok := old[name]; scanned && (!ok || !prev.equal(stamp)) {
+			changed = append(changed, path)
+		}
+	}
+
+	for name := range old {
+		if _, ok := stamps[name]; !ok {
+			changed = append(changed, filepath.Join(dir, name))
+		}
+	}
+
+	s.stamps[dir] = stamps
+	return changed, true
+}
+
+// isWatchedFile reports whether the changes of the file name affect the
+// packages of the project.
+func isWatchedFile(name string) bool {
+	return strings.HasSuffix(name, goext) || name == gomod || name == gosum || isConfigFile(name)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPollScan(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-poll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write := func(name, content string) {
+		filename := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/a.go", "package a\n")
+	write("a/README", "a\n")
+	write("b/b.go", "package b\n")
+
+	s := &pollSubject{
+		observer:  &Project{filter: newWalkFilter(root, DefaultWalkOptions())},
+		interval:  time.Hour,
+		scheduled: make(map[string]bool),
+		stamps:    make(map[string]map[string]fileStamp),
+	}
+	s.schedule(root)
+	for i := 0; i < len(s.dirs); i++ {
+		s.scan(s.dirs[i], nil)
+	}
+
+	write("a/a.go", "package a\n\nvar A int\n")
+	write("a/README", "b\n")
+	write("c/c.go", "package c\n")
+	if err := os.RemoveAll(filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := s.poll()
+	sort.Strings(changed)
+	want := []string{filepath.Join(root, "a", "a.go"), filepath.Join(root, "b"), filepath.Join(root, "c")}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("poll() = %v, want %v", changed, want)
+	}
+
+	if changed := s.poll(); len(changed) != 0 {
+		t.Errorf("second poll() = %v, want none", changed)
+	}
+	if s.scheduled[filepath.Join(root, "b")] || !s.scheduled[filepath.Join(root, "c")] {
+		t.Errorf("scheduled = %v, want c and not b", s.scheduled)
+	}
+}
+
+func TestFileStampEqual(t *testing.T) {
+	now := time.Now()
+	stamp := fileStamp{modTime: now, size: 1}
+	if !stamp.equal(fileStamp{modTime: now.Round(0).In(time.UTC), size: 1}) {
+		t.Error("the stamps of the same instant in another location differ")
+	}
+	if stamp.equal(fileStamp{modTime: now.Add(time.Second), size: 1}) || stamp.equal(fileStamp{modTime: now, size: 2}) {
+		t.Error("the stamps of a changed file are equal")
+	}
+}
+
+func TestPollBurst(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-poll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interval := 50 * time.Millisecond
+	observer := &testObserver{ctx: ctx, dir: root, batches: make(chan []string, 10)}
+	newPollSubject(observer, interval).notify()
+
+	// A burst seen by several polls.
+	writeTestFiles(t, root, map[string]string{"a.go": "package a\n"})
+	time.Sleep(3 * interval)
+	writeTestFiles(t, root, map[string]string{"b.go": "package a\n"})
+
+	select {
+	case batch := <-observer.batches:
+		sort.Strings(batch)
+		if want := []string{filepath.Join(root, "a.go"), filepath.Join(root, "b.go")}; !reflect.DeepEqual(batch, want) {
+			t.Errorf("got batch %v, want %v", batch, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch of the burst")
+	}
+	select {
+	case batch := <-observer.batches:
+		t.Errorf("got another batch %v", batch)
+	case <-time.After(coalesceDelay + 2*interval):
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"golang.org/x/tools/go/packages"
+)
+
+// The priorities of the packages of a load, from the first loaded.
+const (
+	// priorityOpened is the priority of the packages of the opened files.
+	priorityOpened = iota
+	// priorityNeighbor is the priority of the direct imports and importers
+	// of the packages of the opened files.
+	priorityNeighbor
+	// priorityWorkspace is the priority of the other packages of the project.
+	priorityWorkspace
+	// priorityDependency is the priority of the dependencies outside of the
+	// project.
+	priorityDependency
+)
+
+// DidOpen tells that the client opened the file filename. Its package is
+// loaded before the others by the load in progress.
+func (p *Project) DidOpen(filename string) {
+	p.priorityMu.Lock()
+	defer p.priorityMu.Unlock()
+
+	if p.openFiles == nil {
+		p.openFiles = make(map[string]bool)
+	}
+	p.openFiles[util.LowerDriver(filepath.ToSlash(filename))] = true
+	p.priorityGen++
+}
+
+// DidClose tells that the client closed the file filename. The package of a
+// synthetic file is gone with it, as is the secondary view only type-checking
+// the file.
+func (p *Project) DidClose(filename string) {
+	if isSyntheticFile(filename) {
+		p.getCache().Delete(filepath.ToSlash(filename))
+	}
+	p.releaseFile(span.FileURI(filename))
+
+	p.priorityMu.Lock()
+	defer p.priorityMu.Unlock()
+
+	delete(p.openFiles, util.LowerDriver(filepath.ToSlash(filename)))
+}
+
+// priorities returns the opened files, and the generation of the priorities
+// which changes on every DidOpen.
+func (p *Project) priorities() (map[string]bool, int) {
+	p.priorityMu.Lock()
+	defer p.priorityMu.Unlock()
+
+	openFiles := make(map[string]bool, len(p.openFiles))
+	for filename := range p.openFiles {
+		openFiles[filename] = true
+	}
+	return openFiles, p.priorityGen
+}
+
+// sortByPriority moves the go.mod files of the modules containing opened
+// files first, so that they are loaded first.
+func (p *Project) sortByPriority(gomodList []string) {
+	openFiles, _ := p.priorities()
+	opened := func(gomod string) bool {
+		dir := util.LowerDriver(filepath.ToSlash(filepath.Dir(gomod)))
+		for filename := range openFiles {
+			if strings.HasPrefix(filename, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	sort.SliceStable(gomodList, func(i, j int) bool {
+		return opened(gomodList[i]) && !opened(gomodList[j])
+	})
+}
+
+// loadQueue hands out the packages of a load in the order they are
+// type-checked, every package after its dependencies and by priority
+// otherwise. The order is computed again when a file is opened.
+type loadQueue struct {
+	project   *Project
+	order     []*packages.Package
+	importers map[*packages.Package][]*packages.Package
+	done      map[*packages.Package]bool
+
+	plan       []*packages.Package
+	generation int
+}
+
+// newLoadQueue returns the queue of the packages of order, which are sorted
+// after their dependencies.
+func (p *Project) newLoadQueue(order []*packages.Package) *loadQueue {
+	q := &loadQueue{
+		project:    p,
+		order:      order,
+		importers:  make(map[*packages.Package][]*packages.Package),
+		done:       make(map[*packages.Package]bool),
+		generation: -1,
+	}
+	for _, lpkg := range order {
+		for _, imp := range lpkg.Imports {
+			q.importers[imp] = append(q.importers[imp], lpkg)
+		}
+	}
+	return q
+}
+
+// next returns the next package to type-check, or nil once they are all
+// handed out.
+func (q *loadQueue) next() *packages.Package {
+	openFiles, generation := q.project.priorities()
+	if generation != q.generation {
+		q.generation = generation
+		q.plan = q.schedule(openFiles)
+	}
+	if len(q.plan) == 0 {
+		return nil
+	}
+
+	lpkg := q.plan[0]
+	q.plan = q.plan[1:]
+	q.done[lpkg] = true
+	return lpkg
+}
+
+// schedule returns the packages not handed out yet, by priority.
+func (q *loadQueue) schedule(openFiles map[string]bool) []*packages.Package {
+	priorities := make(map[*packages.Package]int, len(q.order))
+	for _, lpkg := range q.order {
+		priorities[lpkg] = priorityWorkspace
+		if q.project.isDependency(lpkg) {
+			priorities[lpkg] = priorityDependency
+		}
+	}
+	for _, lpkg := range q.order {
+		if !containsOpenFile(lpkg, openFiles) {
+			continue
+		}
+		priorities[lpkg] = priorityOpened
+		for _, neighbor := range q.importers[lpkg] {
+			if priorities[neighbor] > priorityNeighbor {
+				priorities[neighbor] = priorityNeighbor
+			}
+		}
+		for _, neighbor := range lpkg.Imports {
+			if priorities[neighbor] > priorityNeighbor {
+				priorities[neighbor] = priorityNeighbor
+			}
+		}
+	}
+
+	var plan []*packages.Package
+	seen := make(map[*packages.Package]bool)
+	var visit func(*packages.Package)
+	visit = func(lpkg *packages.Package) {
+		if seen[lpkg] || q.done[lpkg] {
+			return
+		}
+		seen[lpkg] = true
+		for _, imp := range lpkg.Imports {
+			visit(imp)
+		}
+		plan = append(plan, lpkg)
+	}
+	for priority := priorityOpened; priority <= priorityDependency; priority++ {
+		for _, lpkg := range q.order {
+			if priorities[lpkg] == priority {
+				visit(lpkg)
+			}
+		}
+	}
+	return plan
+}
+
+// containsOpenFile reports whether one of the files of lpkg is opened.
+func containsOpenFile(lpkg *packages.Package, openFiles map[string]bool) bool {
+	if len(openFiles) == 0 {
+		return false
+	}
+	for _, filename := range lpkg.GoFiles {
+		if openFiles[util.LowerDriver(filepath.ToSlash(filename))] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestLoadQueue(t *testing.T) {
+	newPackage := func(id, filename string, imports ...*packages.Package) *packages.Package {
+		lpkg := &packages.Package{ID: id, PkgPath: id, GoFiles: []string{filename}, Imports: make(map[string]*packages.Package)}
+		for _, imp := range imports {
+			lpkg.Imports[imp.PkgPath] = imp
+		}
+		return lpkg
+	}
+	dep := newPackage("dep", "/deps/dep/dep.go")
+	dep2 := newPackage("dep2", "/deps/dep2/dep2.go")
+	a := newPackage("a", "/ws/a/a.go", dep)
+	b := newPackage("b", "/ws/b/b.go", a)
+	c := newPackage("c", "/ws/c/c.go")
+	x := newPackage("x", "/ws/x/x.go", dep2)
+
+	p := &Project{rootDir: "/ws"}
+	q := p.newLoadQueue(postOrder([]*packages.Package{a, b, c, x}))
+
+	ids := func(n int) []string {
+		var got []string
+		for i := 0; i < n; i++ {
+			got = append(got, q.next().ID)
+		}
+		return got
+	}
+
+	p.DidOpen("/ws/c/c.go")
+	if got, want := ids(1), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// The opened file moves its package and the dependencies of the package
+	// to the front of the queue, before the rest of the workspace.
+	p.DidOpen("/ws/x/x.go")
+	if got, want := ids(5), []string{"dep2", "x", "dep", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if lpkg := q.next(); lpkg != nil {
+		t.Errorf("got %s after the last package, want nil", lpkg.ID)
+	}
+}
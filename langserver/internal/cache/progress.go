@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// progressInterval is the minimal interval between two progress reports.
+const progressInterval = 500 * time.Millisecond
+
+// Progress reports the advance of a long running operation of the project.
+type Progress interface {
+	// Report reports the current step of the operation, and the percentage
+	// of the work done.
+	Report(message string, percentage int)
+	// End reports the end of the operation, with its outcome.
+	End(message string)
+}
+
+// ProgressFunc begins the report of the operation title. When the operation
+// is cancellable, the returned context is done once it is cancelled by the
+// user.
+type ProgressFunc func(ctx context.Context, title string, cancellable bool) (context.Context, Progress)
+
+// SetProgress sets how the loads and the rebuilds of the global cache are
+// reported.
+func (p *Project) SetProgress(progress ProgressFunc) {
+	p.progress = progress
+}
+
+func (p *Project) beginProgress(ctx context.Context, title string, cancellable bool) (context.Context, Progress) {
+	if p.progress == nil {
+		return ctx, nopProgress{}
+	}
+	return p.progress(ctx, title, cancellable)
+}
+
+type nopProgress struct{}
+
+func (nopProgress) Report(message string, percentage int) {}
+
+func (nopProgress) End(message string) {}
+
+// loadProgress reports the advance of a load of the global cache, over its
+// load roots, the modules or the GOPATH workspace, and their packages.
+type loadProgress struct {
+	ctx      context.Context
+	progress Progress
+	rootDir  string
+
+	// roots is the number of load roots, root the number of the current
+	// one from 1.
+	roots, root int
+	dir         string
+	last        time.Time
+}
+
+// setLoading sets the report of the current load, nil once it is done.
+func (p *Project) setLoading(loading *loadProgress) {
+	p.loadingMu.Lock()
+	defer p.loadingMu.Unlock()
+	p.loading = loading
+}
+
+// getLoading returns the report of the current load, nil outside of a load.
+func (p *Project) getLoading() *loadProgress {
+	p.loadingMu.Lock()
+	defer p.loadingMu.Unlock()
+	return p.loading
+}
+
+func newLoadProgress(ctx context.Context, progress Progress, rootDir string) *loadProgress {
+	return &loadProgress{ctx: ctx, progress: progress, rootDir: rootDir, roots: 1}
+}
+
+// context returns the context of the load, or ctx outside of a load.
+func (l *loadProgress) context(ctx context.Context) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return l.ctx
+}
+
+// setRoots sets the number of load roots.
+func (l *loadProgress) setRoots(roots int) {
+	if l != nil && roots > 0 {
+		l.roots = roots
+	}
+}
+
+// beginRoot starts the load of the next load root, dir.
+func (l *loadProgress) beginRoot(dir string) {
+	if l == nil {
+		return
+	}
+	if l.root < l.roots {
+		l.root++
+	}
+	l.dir = dir
+	l.report(0, 0)
+}
+
+// report reports that done packages out of total are loaded in the current
+// load root.
+func (l *loadProgress) report(done, total int) {
+	if l == nil || (done < total && time.Since(l.last) < progressInterval) {
+		return
+	}
+	l.last = time.Now()
+
+	fraction := 0.0
+	if total > 0 {
+		fraction = float64(done) / float64(total)
+	}
+	percentage := int((float64(l.root-1) + fraction) * 100 / float64(l.roots))
+
+	message := filepath.Base(l.dir)
+	if rel, err := filepath.Rel(l.rootDir, l.dir); err == nil && rel != "." {
+		message = filepath.ToSlash(rel)
+	}
+	if total > 0 {
+		message = fmt.Sprintf("%s: %d/%d packages", message, done, total)
+	}
+	l.progress.Report(message, percentage)
+}
@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/saibing/bingo/langserver/internal/source"
@@ -26,6 +27,7 @@ import (
 const (
 	goext           = ".go"
 	gomod           = "go.mod"
+	gosum           = "go.sum"
 	vendor          = "vendor"
 	gopathEnv       = "GOPATH"
 	go111module     = "GO111MODULE"
@@ -64,17 +66,109 @@ type FindPackageFunc func(project *Project, importPath string) (source.Package,
 
 // Project project struct
 type Project struct {
-	context       context.Context
-	conn          jsonrpc2.JSONRPC2
-	view          *View
-	rootDir       string
-	vendorDir     string
-	modules       []*module
-	gopath        *gopath
-	cached        bool
-	newCache      *GlobalCache
-	changedCount  int
-	lastBuildTime time.Time
+	context   context.Context
+	conn      jsonrpc2.JSONRPC2
+	view      *View
+	rootDir   string
+	vendorDir string
+	newCache  *GlobalCache
+
+	// modules, gopath and cached are what the last load of the project
+	// found, guarded by stateMu, see setState.
+	stateMu sync.RWMutex
+	modules []*module
+	gopath  *gopath
+	cached  bool
+
+	// rebuildMu serializes the rebuilds of the global cache on file changes.
+	rebuildMu sync.Mutex
+
+	// changes coalesces the file changes reported by the client, see
+	// DidChangeFiles.
+	changes *batcher
+
+	// clientWatcher is set when the client watches the files of the project,
+	// see SetClientWatcher, and watching once the internal file watcher is
+	// started. watchMu guards them.
+	watchMu       sync.Mutex
+	clientWatcher bool
+	watching      bool
+
+	// filter selects the walked directories, see SetWalkOptions.
+	filter *walkFilter
+
+	// watchMode and pollInterval select the internal file watcher, see
+	// SetWatchMode.
+	watchMode    WatchMode
+	pollInterval time.Duration
+
+	// indexDirectory is the directory of the persistent indexes, see
+	// SetIndexDir.
+	indexDirectory string
+
+	// progress reports the loads and the rebuilds, see SetProgress. loading
+	// is the report of the current load of Init or Reload, guarded by
+	// loadingMu.
+	progress  ProgressFunc
+	loadingMu sync.Mutex
+	loading   *loadProgress
+
+	// dependencyMode selects how the dependencies are loaded, see
+	// SetDependencyMode.
+	dependencyMode DependencyMode
+
+	// deps shares the dependencies with the other projects of the
+	// workspace, see SetDependencyCache.
+	deps *DependencyCache
+
+	// memoryLimit is the heap size above which packages are evicted from
+	// the global cache, see SetMemoryLimit. memoryMu guards it: it is read
+	// by watchMemory.
+	memoryMu    sync.Mutex
+	memoryLimit uint64
+
+	// openFiles are the files opened by the client, whose packages are
+	// loaded first, see DidOpen. priorityGen changes on every DidOpen.
+	priorityMu  sync.Mutex
+	openFiles   map[string]bool
+	priorityGen int
+
+	// ready is closed once Init is done loading the project.
+	ready chan struct{}
+
+	// goBinary is the go command run for the project, and driverEnv the
+	// environment variables running it for go/packages, see SetGoBinary.
+	goBinary  string
+	driverEnv []string
+
+	// rootImportPath is the import path of the repository of the project in
+	// GOPATH, see SetRootImportPath.
+	rootImportPath string
+
+	// moduleTags finds the build tags of the modules, see SetModuleTags.
+	// configHandler is told about the changes of the configuration files,
+	// see SetConfigHandler.
+	moduleTags    ModuleTagsFunc
+	configHandler func(filename string)
+
+	// cacheStyle is the style of the global cache, see Init. pendingFlags,
+	// pendingEnv and pendingStyle are the last configuration asked by
+	// Reconfigure, once pendingConfig is set. pendingReload is set by
+	// Reload.
+	cacheStyle    CacheStyle
+	configMu      sync.Mutex
+	pendingConfig bool
+	pendingFlags  []string
+	pendingEnv    []string
+	pendingStyle  CacheStyle
+	pendingReload bool
+
+	// secondaryViews are the views type-checking the opened files excluded
+	// by the build configuration of the main view, indexed by
+	// configuration. fileViews maps these files to their view.
+	viewsMu        sync.Mutex
+	secondaryViews map[string]*View
+	fileViews      map[span.URI]*View
 }
 
 // NewProject new project
@@ -94,12 +188,21 @@ func NewProject(ctx context.Context, conn jsonrpc2.JSONRPC2, rootPath string, bu
 	view := NewView(cfg)
 
 	p := &Project{
-		conn:    conn,
-		view:    view,
-		rootDir: util.LowerDriver(rootPath),
+		context:        ctx,
+		conn:           conn,
+		view:           view,
+		rootDir:        util.LowerDriver(rootPath),
+		ready:          make(chan struct{}),
+		secondaryViews: make(map[string]*View),
+		fileViews:      make(map[span.URI]*View),
 	}
 
+	p.changes = newBatcher(coalesceDelay, p.didChangeFiles)
+
+	view.dirFlags = p.dirFlags
+	view.adhocDir = isAdhocDir
 	p.vendorDir = filepath.Join(p.rootDir, vendor)
+	p.filter = newWalkFilter(p.rootDir, DefaultWalkOptions())
 	return p
 }
 
@@ -107,6 +210,14 @@ func (p *Project) View() source.View {
 	return p.getView()
 }
 
+// buildFlags returns the build flags of the main view.
+func (p *Project) buildFlags() []string {
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Config.BuildFlags
+}
+
 func (p *Project) getView() *View {
 	return p.view
 }
@@ -117,14 +228,22 @@ func (p *Project) notify(err error) {
 	}
 }
 
-// Init init project
+// Init init project. The requests may be served while it is loading the
+// project in the background, see Ready.
 func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
-	p.context = ctx
+	p.cacheStyle = globalCacheStyle
 	start := time.Now()
+	cancelled := false
 	defer func() {
+		close(p.ready)
 		elapsedTime := time.Since(start) / time.Second
+		if cancelled {
+			p.notifyInfo(fmt.Sprintf("load %s cancelled! elapsed time: %d seconds.", p.rootDir, elapsedTime))
+			return
+		}
+		state := p.getState()
 		p.notifyInfo(fmt.Sprintf("load %s successfully! elapsed time: %d seconds, cache: %t, go module: %t.",
-			p.rootDir, elapsedTime, p.cached, len(p.modules) > 0))
+			p.rootDir, elapsedTime, state.cached, len(state.modules) > 0))
 	}()
 
 	if globalCacheStyle == None {
@@ -132,7 +251,10 @@ func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
 	}
 
 	p.newCache = NewCache()
-	p.getView().gcache = p.newCache
+	p.setGlobalCache(p.newCache)
+	if p.getMemoryLimit() > 0 {
+		go p.watchMemory()
+	}
 	err := p.createBuiltin()
 	if err != nil {
 		p.notify(err)
@@ -142,20 +264,114 @@ func (p *Project) Init(ctx context.Context, globalCacheStyle CacheStyle) error {
 		return nil
 	}
 
-	err = p.createProject()
+	loadCtx, progress := p.beginProgress(ctx, "Loading packages", true)
+	p.setLoading(newLoadProgress(loadCtx, progress, p.rootDir))
+	state, err := p.createProject()
+	p.setLoading(nil)
+	p.setState(state)
 	p.notify(err)
-	p.lastBuildTime = time.Now()
 
-	p.fsnotify()
+	if cancelled = loadCtx.Err() != nil; cancelled {
+		progress.End("cancelled")
+	} else {
+		p.newCache.RLock()
+		count := len(p.newCache.idMap)
+		p.newCache.RUnlock()
+		progress.End(fmt.Sprintf("%d packages", count))
+	}
+
+	p.startWatcher()
 	return nil
 }
 
+// Ready returns a channel closed once Init is done loading the project.
+// Until then, the global cache only holds the packages loaded so far.
+func (p *Project) Ready() <-chan struct{} {
+	return p.ready
+}
+
+// Loading reports whether Init is still loading the project.
+func (p *Project) Loading() bool {
+	select {
+	case <-p.ready:
+		return false
+	default:
+		return true
+	}
+}
+
+// SetClientWatcher sets whether the client watches the files of the project
+// and reports their changes through DidChangeFiles. The internal file system
+// watcher is not started by Init in that case.
+func (p *Project) SetClientWatcher(enabled bool) {
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	p.clientWatcher = enabled
+}
+
+// SetWatchMode selects how the internal file watcher watches the files of
+// the project, and how often they are polled in WatchPoll mode or after
+// falling back from fsnotify in WatchAuto mode.
+func (p *Project) SetWatchMode(mode WatchMode, pollInterval time.Duration) {
+	p.watchMode = mode
+	p.pollInterval = pollInterval
+}
+
+// Watch starts the internal file system watcher, when the client failed to
+// watch the files of the project. It is started once the project is loaded.
+func (p *Project) Watch() {
+	go func() {
+		<-p.ready
+		p.watchMu.Lock()
+		p.clientWatcher = false
+		p.watchMu.Unlock()
+		p.startWatcher()
+	}()
+}
+
+// startWatcher starts the internal file system watcher of the cached
+// project, unless the client watches its files or it is already started.
+func (p *Project) startWatcher() {
+	p.watchMu.Lock()
+	defer p.watchMu.Unlock()
+	if p.clientWatcher || p.watching || !p.getState().cached {
+		return
+	}
+	p.watching = true
+	p.fsnotify()
+}
+
+// DidChangeFiles rebuilds the packages affected by the creation, change or
+// deletion of the files filenames reported by the client. The changes of a
+// burst are coalesced into a single rebuild, as the ones of the internal file
+// watcher, and the changes reported while the project is loading are applied
+// in order once it is loaded.
+func (p *Project) DidChangeFiles(filenames []string) {
+	p.changes.add(filenames...)
+}
+
+// didChangeFiles rebuilds the packages affected by the batch of file changes
+// reported by the client. The changes reported during a reload are applied
+// once it is done, see rebuild.
+func (p *Project) didChangeFiles(filenames []string) {
+	<-p.ready
+	p.update(filenames)
+}
+
 func (p *Project) fsnotify() {
-	if !p.cached {
+	if !p.getState().cached {
 		return
 	}
 
-	subject := newSubject(p)
+	var subject Subject
+	switch p.watchMode {
+	case WatchPoll:
+		subject = newPollSubject(p, p.pollInterval)
+	case WatchFsnotify:
+		subject = newSubject(p, nil)
+	default:
+		subject = newSubject(p, newPollSubject(p, p.pollInterval))
+	}
 	go subject.notify()
 }
 
@@ -165,11 +381,17 @@ func (p *Project) Contain(fileURI lsp.DocumentURI) bool {
 }
 
 func (p *Project) getImportPath() string {
+	return gopathImportPath(p.rootDir)
+}
+
+// gopathImportPath returns the import path of the directory dir in GOPATH, or
+// "" if dir is outside of GOPATH.
+func gopathImportPath(dir string) string {
 	for _, path := range gopaths {
 		path = util.LowerDriver(filepath.ToSlash(path))
 		srcDir := filepath.Join(path, "src")
-		if strings.HasPrefix(p.rootDir, srcDir) && p.rootDir != srcDir {
-			return filepath.ToSlash(p.rootDir[len(srcDir)+1:])
+		if strings.HasPrefix(dir, srcDir) && dir != srcDir {
+			return filepath.ToSlash(dir[len(srcDir)+1:])
 		}
 	}
 
@@ -180,14 +402,10 @@ func (p *Project) isUnderGoroot() bool {
 	return strings.HasPrefix(p.rootDir, goroot)
 }
 
-var siteLenMap = map[string]int{
-	"github.com": 3,
-	"golang.org": 3,
-	"gopkg.in":   2,
-}
-
-func (p *Project) createProject() error {
-	value := os.Getenv(go111module)
+// createProject loads the packages of the project into the new global cache,
+// and returns what it found.
+func (p *Project) createProject() (loadState, error) {
+	value := p.getenv(go111module)
 
 	if value == "on" {
 		p.notifyLog("GO111MODULE=on, module mode")
@@ -209,17 +427,16 @@ func (p *Project) createProject() error {
 	}
 
 	if importPath == "" {
-		return fmt.Errorf("%s is out of GOPATH workspace %v", p.rootDir, gopaths)
+		p.notifyLog(fmt.Sprintf("%s is out of GOPATH workspace %v, ad-hoc mode", p.rootDir, gopaths))
+		return loadState{}, nil
 	}
 
-	dirs := strings.Split(importPath, "/")
-	siteLen := siteLenMap[dirs[0]]
-
-	if len(dirs) < siteLen {
-		return fmt.Errorf("%s is not correct root dir of project.", p.rootDir)
+	repository, err := p.repositoryImportPath(importPath)
+	if err != nil {
+		return loadState{}, err
 	}
 
-	p.notifyLog("GOPATH mode")
+	p.notifyLog(fmt.Sprintf("GOPATH mode, repository: %s", repository))
 	return p.createGoPath(importPath, false)
 }
 
@@ -231,31 +448,62 @@ func (p *Project) GetBuiltinPackage() source.Package {
 	return p.GetFromPkgPath(BuiltinPkg)
 }
 
-func (p *Project) createGoModule(gomodList []string) error {
+func (p *Project) createGoModule(gomodList []string) (loadState, error) {
+	var state loadState
+	loading := p.getLoading()
+	loading.setRoots(len(gomodList))
+	p.sortByPriority(gomodList)
 	for _, v := range gomodList {
+		if err := loading.context(p.context).Err(); err != nil {
+			return state, err
+		}
 		module := newModule(p, util.LowerDriver(filepath.Dir(v)))
 		err := module.init()
 		p.notify(err)
-		p.modules = append(p.modules, module)
+		state.modules = append(state.modules, module)
 	}
 
-	if len(p.modules) == 0 {
-		return nil
+	if len(state.modules) == 0 {
+		p.notifyLog(fmt.Sprintf("no go.mod file in %s, ad-hoc mode", p.rootDir))
+		return state, nil
 	}
 
-	p.cached = true
-	sort.Slice(p.modules, func(i, j int) bool {
-		return p.modules[i].rootDir >= p.modules[j].rootDir
+	state.cached = true
+	sort.Slice(state.modules, func(i, j int) bool {
+		return state.modules[i].rootDir >= state.modules[j].rootDir
 	})
 
-	return nil
+	return state, nil
+}
+
+func (p *Project) createGoPath(importPath string, underGoroot bool) (loadState, error) {
+	workspace := newGopath(p, p.rootDir, importPath, underGoroot)
+	err := workspace.init()
+	return loadState{gopath: workspace, cached: err == nil}, err
+}
+
+// loadState is what a load of the project found: the modules of the project
+// in module mode, sorted with the innermost ones first, or its GOPATH
+// workspace, and whether its packages are in the global cache.
+type loadState struct {
+	modules []*module
+	gopath  *gopath
+	cached  bool
+}
+
+// getState returns what the last load of the project found.
+func (p *Project) getState() loadState {
+	p.stateMu.RLock()
+	defer p.stateMu.RUnlock()
+	return loadState{modules: p.modules, gopath: p.gopath, cached: p.cached}
 }
 
-func (p *Project) createGoPath(importPath string, underGoroot bool) error {
-	gopath := newGopath(p, p.rootDir, importPath, underGoroot)
-	err := gopath.init()
-	p.cached = err == nil
-	return err
+// setState sets what the last load of the project found. A reload sets it
+// at once, the previous state is used until then.
+func (p *Project) setState(state loadState) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.modules, p.gopath, p.cached = state.modules, state.gopath, state.cached
 }
 
 func (p *Project) createBuiltin() error {
@@ -275,35 +523,19 @@ func (p *Project) createBuiltin() error {
 func (p *Project) findGoModFiles() []string {
 	var gomodList []string
 	walkFunc := func(path string, name string) {
-		if name == gomod {
+		if name == gomod && p.filter.included(path) {
 			fullpath := filepath.Join(path, name)
 			gomodList = append(gomodList, fullpath)
 			p.notifyLog(fullpath)
 		}
 	}
 
-	err := p.walkDir(p.rootDir, 0, walkFunc)
+	err := p.walkDir(p.rootDir, walkFunc)
 	p.notify(err)
 	return gomodList
 }
 
-var defaultExcludeDir = []string{".git", ".svn", ".hg", ".vscode", ".idea", "node_modules", vendor}
-
-func isExclude(dir string) bool {
-	for _, d := range defaultExcludeDir {
-		if d == dir {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, string)) error {
-	if level > 8 {
-		return nil
-	}
-
+func (p *Project) walkDir(rootDir string, walkFunc func(string, string)) error {
 	files, err := ioutil.ReadDir(rootDir)
 	if err != nil {
 		p.notify(err)
@@ -311,17 +543,16 @@ func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, strin
 	}
 
 	for _, fi := range files {
-		if isExclude(fi.Name()) {
-			continue
-		}
-
+		fullpath := filepath.Join(rootDir, fi.Name())
 		if fi.IsDir() {
-			level++
-			err = p.walkDir(filepath.Join(rootDir, fi.Name()), level, walkFunc)
+			if p.skipDir(fullpath) {
+				continue
+			}
+
+			err = p.walkDir(fullpath, walkFunc)
 			if err != nil {
 				return err
 			}
-			level--
 		} else {
 			walkFunc(rootDir, fi.Name())
 		}
@@ -330,6 +561,24 @@ func (p *Project) walkDir(rootDir string, level int, walkFunc func(string, strin
 	return nil
 }
 
+// SetWalkOptions selects the directories of the project which are walked
+// for go.mod files, watched and loaded.
+func (p *Project) SetWalkOptions(options WalkOptions) {
+	p.filter = newWalkFilter(p.rootDir, options)
+}
+
+// skipDir reports whether the directory dir and the ones below it are left
+// out of the walks of the project.
+func (p *Project) skipDir(dir string) bool {
+	return p.filter.skip(dir)
+}
+
+// loadable reports whether the packages of dir are loaded into the global
+// cache.
+func (p *Project) loadable(dir string) bool {
+	return !p.filter.excluded(dir) && p.filter.included(dir)
+}
+
 // GetFromURI get package from document uri.
 func (p *Project) GetFromURI(uri lsp.DocumentURI) source.Package {
 	filename, _ := source.FromDocumentURI(uri).Filename()
@@ -342,9 +591,9 @@ func (p *Project) GetFromURI(uri lsp.DocumentURI) source.Package {
 }
 
 func (p *Project) getCache() *GlobalCache {
-	p.view.mu.Lock()
+	p.view.gcacheMu.Lock()
 	cache := p.view.gcache
-	p.view.mu.Unlock()
+	p.view.gcacheMu.Unlock()
 	return cache
 }
 
@@ -357,85 +606,6 @@ func (p *Project) GetFromPkgPath(pkgPath string) source.Package {
 	return pkg.Package()
 }
 
-func (p *Project) update(eventName string) {
-	if p.needRebuild(eventName) {
-		p.notifyLog("fsnotify " + eventName)
-		p.newCache = NewCache()
-		p.newCache.Put(p.GetBuiltinPackage().(*Package))
-		p.rebuildGopapthCache(eventName)
-		p.rebuildModuleCache(eventName)
-		p.lastBuildTime = time.Now()
-
-		p.view.mu.Lock()
-		p.view.gcache = p.newCache
-		p.view.mu.Unlock()
-	}
-}
-
-func (p *Project) needRebuild(eventName string) bool {
-	if strings.HasSuffix(eventName, gomod) {
-		return true
-	}
-
-	if strings.HasPrefix(eventName, emacsLockPrefix) {
-		return false
-	}
-
-	if !strings.HasSuffix(eventName, goext) {
-		return false
-	}
-
-	uri := span.NewURI(eventName)
-	v := p.getView()
-	v.mu.Lock()
-	f := v.files[uri]
-	v.mu.Unlock()
-	if f != nil {
-		return false
-	}
-
-	p.changedCount++
-
-	if p.changedCount > 20 {
-		p.changedCount = 0
-		return true
-	}
-
-	return time.Now().Sub(p.lastBuildTime) >= 60*time.Second
-}
-
-func (p *Project) rebuildGopapthCache(eventName string) {
-	if p.gopath == nil {
-		return
-	}
-
-	if strings.HasSuffix(eventName, p.gopath.rootDir) {
-		p.gopath.rebuildCache()
-	}
-}
-
-func (p *Project) rebuildModuleCache(eventName string) {
-	if len(p.modules) == 0 {
-		return
-	}
-
-	for _, m := range p.modules {
-		if strings.HasPrefix(filepath.Dir(eventName), m.rootDir) {
-			rebuild, err := m.rebuildCache()
-			if err != nil {
-				p.notifyError(err.Error())
-				return
-			}
-
-			if rebuild {
-				p.notifyInfo(fmt.Sprintf("rebuild module cache for %s changed", eventName))
-			}
-
-			return
-		}
-	}
-}
-
 // NotifyError notify error to lsp client
 func (p *Project) notifyError(message string) {
 	_ = p.conn.Notify(p.context, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.MTError, Message: message})
@@ -459,10 +629,14 @@ func (p *Project) getContext() context.Context {
 	return p.context
 }
 
-// Search serach package cache
+// Search serach package cache. While the project is loading, only the
+// packages loaded so far are walked.
 func (p *Project) Search(walkFunc source.WalkFunc) error {
 	var ranks []string
-	for _, module := range p.modules {
+	if p.Loading() {
+		return p.getCache().Walk(walkFunc, ranks)
+	}
+	for _, module := range p.getState().modules {
 		if module.mainModulePath == "." || module.mainModulePath == "" {
 			continue
 		}
@@ -472,20 +646,43 @@ func (p *Project) Search(walkFunc source.WalkFunc) error {
 	return p.getCache().Walk(walkFunc, ranks)
 }
 
-func (p *Project) setCache(pkgs []*packages.Package) {
-	for _, pkg := range pkgs {
-		p.newCache.Add(pkg)
+func (p *Project) Cache() *GlobalCache {
+	return p.getCache()
+}
+
+// ReverseDependencies returns the packages of the project which depend on the
+// package pkgPath, following at most depth levels of importers.
+func (p *Project) ReverseDependencies(pkgPath string, depth int) []source.Package {
+	var pkgs []source.Package
+	for _, pkg := range p.getCache().Importers(pkgPath, depth) {
+		if len(pkg.files) == 0 || !p.isInsideProject(pkg.files[0]) {
+			continue
+		}
+		pkgs = append(pkgs, pkg)
 	}
+
+	return pkgs
 }
 
-func (p *Project) Cache() *GlobalCache {
-	return p.getCache()
+// CheckPackage type-checks pkg again in its view, without opening its files.
+// The reverse dependencies of an edited package are diagnosed with it.
+func (p *Project) CheckPackage(ctx context.Context, pkg source.Package) (source.Package, error) {
+	filenames := pkg.GetFilenames()
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("package %s has no files", pkg.GetPkgPath())
+	}
+
+	checked, err := p.viewFor(span.FileURI(filenames[0])).checkPackage(ctx, pkg.GetPkgPath(), filenames[0])
+	if err != nil {
+		return nil, err
+	}
+	return checked, nil
 }
 
 func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (source.Package, source.File, error) {
 	uri := span.FromDocumentURI(fileURI)
 
-	v := p.getView()
+	v := p.viewFor(uri)
 	v.mu.Lock()
 	f := v.files[uri]
 	v.mu.Unlock()
@@ -498,7 +695,6 @@ func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (sourc
 		}
 
 		if f == nil {
-			v := p.getView()
 			v.mu.Lock()
 			f = v.getFile(uri)
 			v.mu.Unlock()
@@ -513,10 +709,11 @@ func (p *Project) TypeCheck(ctx context.Context, fileURI lsp.DocumentURI) (sourc
 	return pkg, f, nil
 }
 
-func (p *Project) isInsideProject(path string) bool {
-	return strings.HasPrefix(filepath.ToSlash(path), p.rootDir)
+// IsInside reports whether the file or directory path belongs to the project.
+func (p *Project) IsInside(path string) bool {
+	return p.isInsideProject(path)
 }
 
-func newSubject(observer Observer) Subject {
-	return &fsSubject{observer: observer}
+func (p *Project) isInsideProject(path string) bool {
+	return strings.HasPrefix(filepath.ToSlash(path), p.rootDir)
 }
@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// update rebuilds the packages affected by the batch of file events at once.
+func (p *Project) update(events []string) {
+	dirs := make(map[string]bool)
+	var trees []string
+	for _, event := range events {
+		eventDirs, eventTrees := p.changedDirs(event)
+		for dir := range eventDirs {
+			dirs[dir] = true
+		}
+		trees = append(trees, eventTrees...)
+	}
+
+	if len(dirs) == 0 && len(trees) == 0 {
+		return
+	}
+
+	p.notifyLog(fmt.Sprintf("%d files changed", len(events)))
+	if err := p.rebuild(dirs, trees); err != nil {
+		p.notifyError(err.Error())
+	}
+}
+
+// changedDirs returns the package directories affected by the creation,
+// modification, removal or renaming of path. The packages of the trees must
+// be rebuilt as a whole.
+func (p *Project) changedDirs(path string) (dirs map[string]bool, trees []string) {
+	name := filepath.Base(path)
+	if p.filter.excluded(filepath.Dir(path)) {
+		return nil, nil
+	}
+
+	switch {
+	case strings.HasPrefix(name, emacsLockPrefix):
+		return nil, nil
+	case isConfigFile(name):
+		if p.configHandler != nil {
+			p.configHandler(path)
+		}
+		return nil, nil
+	case isIgnoreFile(name):
+		// The directories it ignores are only taken into account by the
+		// next walks.
+		p.filter.forget(path)
+		return nil, nil
+	case name == gomod || name == gosum:
+		dir := packageDir(path)
+		for _, m := range p.getState().modules {
+			if filepath.ToSlash(m.rootDir) != dir {
+				continue
+			}
+			if _, err := m.checkModuleCache(); err != nil {
+				p.notifyError(err.Error())
+			}
+		}
+		return nil, []string{dir}
+	case strings.HasSuffix(name, goext):
+		// The opened documents are type-checked with their content in the
+		// editor, not the one on the disk.
+		if p.isOpen(path) {
+			return nil, nil
+		}
+		return map[string]bool{packageDir(path): true}, nil
+	}
+
+	// A directory was created, removed or renamed.
+	fi, err := os.Stat(path)
+	if (err == nil && fi.IsDir()) || os.IsNotExist(err) {
+		return nil, []string{util.LowerDriver(filepath.ToSlash(path))}
+	}
+	return nil, nil
+}
+
+func (p *Project) isOpen(filename string) bool {
+	uri := span.FileURI(filename)
+	for _, v := range p.views() {
+		v.mu.Lock()
+		f := v.files[uri]
+		open := f != nil && f.active
+		v.mu.Unlock()
+		if open {
+			return true
+		}
+	}
+	return false
+}
+
+// views returns the main view and the secondary views of the project.
+func (p *Project) views() []*View {
+	p.viewsMu.Lock()
+	defer p.viewsMu.Unlock()
+
+	views := []*View{p.view}
+	for _, v := range p.secondaryViews {
+		views = append(views, v)
+	}
+	return views
+}
+
+// rebuild reloads the packages of the directories dirs and below trees,
+// together with their reverse dependencies, and swaps them into the global
+// cache at once. The unchanged packages they import are shared with the
+// previous cache instead of being type-checked again.
+func (p *Project) rebuild(dirs map[string]bool, trees []string) error {
+	p.rebuildMu.Lock()
+	defer p.rebuildMu.Unlock()
+
+	cache := p.getCache()
+	if cache == nil || !p.getState().cached {
+		return nil
+	}
+	if dirs == nil {
+		dirs = make(map[string]bool)
+	}
+
+	stale := make(map[string]string)
+	for _, pkg := range cache.PackagesIn(dirs, trees) {
+		stale[pkg.id] = pkg.pkgPath
+		dirs[packageDir(pkg.files[0])] = true
+		for _, importer := range cache.Importers(pkg.pkgPath, math.MaxInt32) {
+			if len(importer.files) == 0 {
+				continue
+			}
+			stale[importer.id] = importer.pkgPath
+			dirs[packageDir(importer.files[0])] = true
+		}
+	}
+
+	patterns := make(map[string][]string)
+	addPattern := func(dir, pattern string) {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() || p.filter.excluded(dir) {
+			return
+		}
+		if root := p.loadRoot(dir); root != "" {
+			patterns[root] = append(patterns[root], filepath.FromSlash(pattern))
+		}
+	}
+	for dir := range dirs {
+		if !underTrees(dir, trees) {
+			addPattern(dir, dir)
+		}
+	}
+	for _, tree := range trees {
+		addPattern(tree, tree+"/...")
+	}
+
+	if len(stale) == 0 && len(patterns) == 0 {
+		return nil
+	}
+
+	_, progress := p.beginProgress(p.context, "Rebuilding packages", false)
+	rebuilding := newLoadProgress(p.context, progress, p.rootDir)
+	rebuilding.beginRoot(p.rootDir)
+	var checked int
+	defer func() {
+		progress.End(fmt.Sprintf("%d packages type-checked", checked))
+	}()
+
+	v := p.getView()
+	v.mu.Lock()
+	cfg := v.Config
+	v.mu.Unlock()
+
+	// The global cache holds the packages as they are on the disk.
+	cfg.Overlay = nil
+	cfg.Mode = packages.LoadImports
+	sizes := configSizes(&cfg)
+
+	var roots []*packages.Package
+	flags := cfg.BuildFlags
+	for root, list := range patterns {
+		cfg.Dir = root
+		cfg.BuildFlags = p.dirFlags(root, flags)
+		pkgs, err := packages.Load(&cfg, list...)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, p.loadableRoots(pkgs)...)
+	}
+
+	order := postOrder(roots)
+	for i, lpkg := range order {
+		rebuilding.report(i, len(order))
+		lpkg.Fset = cfg.Fset
+		files := lpkg.CompiledGoFiles
+		if len(files) == 0 {
+			files = lpkg.GoFiles
+		}
+
+		if _, ok := stale[lpkg.ID]; !ok {
+			cache.RLock()
+			cached := cache.get(lpkg.ID)
+			cache.RUnlock()
+			if cached != nil && sameFiles(cached.files, files) {
+				lpkg.Types = cached.types
+				continue
+			}
+		}
+
+		stale[lpkg.ID] = lpkg.PkgPath
+		checkPackage(&cfg, lpkg, files, sizes)
+		checked++
+	}
+
+	var pkgs []*packages.Package
+	for _, lpkg := range roots {
+		// The directories left without Go files have no package any more.
+		if len(lpkg.CompiledGoFiles) > 0 || len(lpkg.GoFiles) > 0 {
+			pkgs = append(pkgs, lpkg)
+		}
+	}
+
+	var idList []string
+	for id := range stale {
+		idList = append(idList, id)
+	}
+	sort.Strings(idList)
+	cache.Replace(idList, pkgs)
+	p.invalidate(stale)
+
+	p.notifyLog(fmt.Sprintf("rebuild %d packages, %d packages type-checked", len(pkgs), checked))
+	return nil
+}
+
+// loadRoot returns the directory from which the package of dir is loaded, or
+// "" if dir is outside of the project.
+func (p *Project) loadRoot(dir string) string {
+	// The modules are sorted with the innermost ones first.
+	state := p.getState()
+	for _, m := range state.modules {
+		root := filepath.ToSlash(m.rootDir)
+		if dir == root || strings.HasPrefix(dir, root+"/") {
+			return m.rootDir
+		}
+	}
+
+	if state.gopath != nil && p.isInsideProject(dir) {
+		return state.gopath.rootDir
+	}
+	return ""
+}
+
+// invalidate removes the stale packages and their reverse dependencies from
+// the package caches of the views, so that the opened documents importing
+// them are type-checked again.
+func (p *Project) invalidate(stale map[string]string) {
+	for _, v := range p.views() {
+		v.mu.Lock()
+		v.mcache.mu.Lock()
+		v.pcache.mu.Lock()
+		seen := make(map[string]bool)
+		for _, pkgPath := range stale {
+			v.remove(pkgPath, seen)
+		}
+		v.pcache.mu.Unlock()
+		v.mcache.mu.Unlock()
+		v.mu.Unlock()
+	}
+}
+
+func underTrees(dir string, trees []string) bool {
+	for _, tree := range trees {
+		if dir == tree || strings.HasPrefix(dir, tree+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebuildChangedPackage(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nfunc F() {}\n",
+		"b/b.go": "package b\n\nimport \"example.com/m/a\"\n\nvar _ = a.F\n",
+		"c/c.go": "package c\n\nimport \"example.com/m/a\"\n\nvar _ = a.F\n",
+		"d/d.go": "package d\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, root, nil)
+	p.SetIndexDir("none")
+	p.SetEnv([]string{"GO111MODULE=on", "GOFLAGS=-mod=mod"})
+	p.setState(loadState{modules: []*module{newModule(p, root)}, cached: true})
+	p.newCache = NewCache()
+	cfg := p.view.Config
+	if err := p.loadCache(cfg, root+"/..."); err != nil {
+		t.Fatal(err)
+	}
+	p.setGlobalCache(p.newCache)
+
+	packages := func() map[string]*Package {
+		c := p.getCache()
+		c.RLock()
+		defer c.RUnlock()
+		pkgs := make(map[string]*Package)
+		for _, id := range []string{"a", "b", "c", "d"} {
+			pkgs[id] = c.get("example.com/m/" + id)
+		}
+		return pkgs
+	}
+	before := packages()
+
+	writeTestFiles(t, root, map[string]string{"a/a.go": "package a\n\nfunc G() {}\n"})
+	p.update([]string{filepath.Join(root, "a", "a.go")})
+
+	// Only the edited package and its importers are type-checked again.
+	after := packages()
+	for id, rebuilt := range map[string]bool{"a": true, "b": true, "c": true, "d": false} {
+		if (after[id] != before[id]) != rebuilt {
+			t.Errorf("package %s rebuilt: %v, want %v", id, after[id] != before[id], rebuilt)
+		}
+	}
+	if after["a"].GetTypes().Scope().Lookup("G") == nil {
+		t.Error("the edited package is not type-checked again")
+	}
+	if imp := after["b"].GetImport("example.com/m/a"); imp != after["a"] {
+		t.Error("the importer doesn't import the rebuilt package")
+	}
+	if errs := after["c"].GetErrors(); len(errs) != 1 {
+		t.Errorf("got errors %v for the importer, want the undeclared a.F", errs)
+	}
+}
+
+func TestLoadBelowMaxDepth(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	deep := "a/b/c/d/e/f/g/h/i/j"
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":       "module example.com/m\n",
+		deep + "/j.go": "package j\n\nfunc F() {}\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, root, nil)
+	p.SetIndexDir("none")
+	p.SetEnv([]string{"GO111MODULE=on", "GOFLAGS=-mod=mod"})
+	p.setState(loadState{modules: []*module{newModule(p, root)}, cached: true})
+	p.newCache = NewCache()
+	if err := p.loadCache(p.view.Config, root+"/..."); err != nil {
+		t.Fatal(err)
+	}
+	p.setGlobalCache(p.newCache)
+
+	if !p.skipDir(filepath.Join(root, filepath.FromSlash(deep))) {
+		t.Errorf("%s is walked, want it below the maximum depth %d", deep, DefaultMaxDepth)
+	}
+	c := p.getCache()
+	c.RLock()
+	defer c.RUnlock()
+	if pkg := c.get("example.com/m/" + deep); pkg == nil {
+		t.Errorf("the package %s below the maximum depth is not loaded", deep)
+	}
+}
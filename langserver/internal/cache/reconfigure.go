@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// Reconfigure type-checks the project with new build flags, new environment
+// variables, as set by SetEnv, and a new style of global cache, once it is
+// loaded. The views forget the packages they type-checked, the opened
+// documents are routed again to the views matching their build constraints,
+// and the global cache is loaded again in the background. The requests are
+// served by the previous global cache until the new one is swapped in.
+func (p *Project) Reconfigure(buildFlags []string, env []string, globalCacheStyle CacheStyle) {
+	p.configMu.Lock()
+	p.pendingConfig = true
+	p.pendingFlags = buildFlags
+	p.pendingEnv = p.configEnv(env)
+	p.pendingStyle = globalCacheStyle
+	p.configMu.Unlock()
+
+	go p.applyConfig()
+}
+
+// Reload type-checks the project again with the same configuration, once it
+// is loaded, so that the build tags of the modules are read again, see
+// SetModuleTags.
+func (p *Project) Reload() {
+	p.configMu.Lock()
+	p.pendingReload = true
+	p.configMu.Unlock()
+
+	go p.applyConfig()
+}
+
+// applyConfig applies the configuration asked by Reconfigure and Reload.
+func (p *Project) applyConfig() {
+	<-p.ready
+	if p.context.Err() != nil {
+		return
+	}
+
+	p.rebuildMu.Lock()
+	defer p.rebuildMu.Unlock()
+
+	// A later call may have changed the configuration again, the last one
+	// is applied once.
+	p.configMu.Lock()
+	pending, force := p.pendingConfig, p.pendingReload
+	buildFlags, env, globalCacheStyle := p.pendingFlags, p.pendingEnv, p.pendingStyle
+	p.pendingReload = false
+	p.configMu.Unlock()
+
+	v := p.getView()
+	v.mu.Lock()
+	if !pending {
+		buildFlags, env = v.Config.BuildFlags, v.Config.Env
+	}
+	configChanged := !reflect.DeepEqual(v.Config.BuildFlags, buildFlags) || !reflect.DeepEqual(v.Config.Env, env)
+	v.mu.Unlock()
+	if globalCacheStyle == "" {
+		globalCacheStyle = p.cacheStyle
+	}
+	if !force && !configChanged && globalCacheStyle == p.cacheStyle {
+		return
+	}
+
+	if configChanged || force {
+		p.setBuildConfig(buildFlags, env)
+		p.reroute()
+	}
+	p.reload(globalCacheStyle)
+	if force {
+		// The build tags of the modules are known once they are loaded.
+		p.reroute()
+	}
+}
+
+// setBuildConfig replaces the build flags and the environment of the main
+// view, which forgets the packages type-checked with the previous ones. The
+// secondary views, built from the main configuration, are dropped, their
+// documents are moved to new views by reroute.
+func (p *Project) setBuildConfig(buildFlags []string, env []string) {
+	p.viewsMu.Lock()
+	for key, v := range p.secondaryViews {
+		v.mu.Lock()
+		v.cancel()
+		v.mu.Unlock()
+		delete(p.secondaryViews, key)
+	}
+	p.viewsMu.Unlock()
+
+	v := p.getView()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+	v.pcache.mu.Lock()
+	defer v.pcache.mu.Unlock()
+
+	v.cancel()
+	v.backgroundCtx, v.cancel = context.WithCancel(context.Background())
+	v.Config.BuildFlags = buildFlags
+	v.Config.Env = env
+	v.mcache.packages = make(map[string]*metadata)
+	v.pcache.packages = make(map[string]*entry)
+	for _, f := range v.files {
+		f.pkg = nil
+		f.meta = nil
+	}
+}
+
+// reroute routes the opened documents again, after a change of the build
+// configuration of the main view.
+func (p *Project) reroute() {
+	// The documents of the secondary views dropped by setBuildConfig are
+	// routed again too.
+	views := p.views()
+	seen := make(map[*View]bool)
+	for _, v := range views {
+		seen[v] = true
+	}
+	p.viewsMu.Lock()
+	for _, v := range p.fileViews {
+		if !seen[v] {
+			seen[v] = true
+			views = append(views, v)
+		}
+	}
+	p.viewsMu.Unlock()
+	for _, v := range views {
+		for uri, content := range v.activeFiles() {
+			if configuration, changed := p.RouteFile(p.context, uri, content); changed {
+				_ = p.viewFor(uri).SetContent(p.context, uri, content)
+				filename, _ := uri.Filename()
+				if configuration == "" {
+					configuration = "the workspace configuration"
+				}
+				p.notifyLog(fmt.Sprintf("%s is type-checked with %s", filename, configuration))
+			}
+		}
+	}
+}
+
+// activeFiles returns the content of the opened documents of the view.
+func (v *View) activeFiles() map[span.URI][]byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.mcache.mu.Lock()
+	defer v.mcache.mu.Unlock()
+
+	_ = v.applyContentChanges(context.Background())
+	files := make(map[span.URI][]byte)
+	for uri, f := range v.files {
+		if f.active {
+			files[uri] = f.content
+		}
+	}
+	return files
+}
+
+// reload loads the global cache again with globalCacheStyle, and swaps it
+// in together with the modules or the GOPATH workspace it found, once it is
+// loaded. It is called with rebuildMu held.
+func (p *Project) reload(globalCacheStyle CacheStyle) {
+	start := time.Now()
+	previous := p.getCache()
+	cached := p.getState().cached
+	p.cacheStyle = globalCacheStyle
+
+	if globalCacheStyle == None {
+		p.newCache = nil
+		p.setGlobalCache(nil)
+		p.setState(loadState{})
+		p.notifyInfo(fmt.Sprintf("global cache of %s disabled", p.rootDir))
+		return
+	}
+
+	p.newCache = NewCache()
+	p.notify(p.createBuiltin())
+	var state loadState
+	if globalCacheStyle == Always {
+		loadCtx, progress := p.beginProgress(p.context, "Reloading packages", true)
+		p.setLoading(newLoadProgress(loadCtx, progress, p.rootDir))
+		var err error
+		state, err = p.createProject()
+		p.setLoading(nil)
+		p.notify(err)
+
+		if loadCtx.Err() != nil {
+			progress.End("cancelled")
+		} else {
+			p.newCache.RLock()
+			count := len(p.newCache.idMap)
+			p.newCache.RUnlock()
+			progress.End(fmt.Sprintf("%d packages", count))
+		}
+	}
+	p.setGlobalCache(p.newCache)
+	p.setState(state)
+
+	if previous == nil && p.getMemoryLimit() > 0 {
+		go p.watchMemory()
+	}
+	if !cached && state.cached {
+		p.startWatcher()
+	}
+	p.notifyInfo(fmt.Sprintf("reload %s successfully! elapsed time: %d seconds, cache: %t, go module: %t.",
+		p.rootDir, time.Since(start)/time.Second, state.cached, len(state.modules) > 0))
+}
+
+// setGlobalCache makes c the global cache of the main view.
+func (p *Project) setGlobalCache(c *GlobalCache) {
+	v := p.getView()
+	v.mu.Lock()
+	v.gcacheMu.Lock()
+	v.gcache = c
+	v.gcacheMu.Unlock()
+	v.mu.Unlock()
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReconfigureEnv(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-reconfigure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":   "module example.com/m\n",
+		"a/a.go":   "package a\n",
+		"a/x_x.go": "// +build x\n\npackage a\n\nfunc X() {}\n",
+	})
+
+	p := NewProject(context.Background(), testConn{}, root, nil)
+	p.SetIndexDir("none")
+	p.SetEnv([]string{"GO111MODULE=on", "GOFLAGS=-mod=mod"})
+	if err := p.Init(context.Background(), Always); err != nil {
+		t.Fatal(err)
+	}
+	before := p.getState()
+	if !before.cached || len(before.modules) != 1 {
+		t.Fatalf("got state %+v, want a cached module", before)
+	}
+
+	// The previous state is kept until the reload swaps the new one in.
+	p.Reconfigure(nil, []string{"GO111MODULE=on", "GOFLAGS=-mod=mod -tags=x"}, Always)
+	var after loadState
+	for i := 0; ; i++ {
+		after = p.getState()
+		if !after.cached {
+			t.Fatal("the project is not cached during the reload")
+		}
+		if after.modules[0] != before.modules[0] {
+			break
+		}
+		if i == 500 {
+			t.Fatal("the project is not reloaded")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got := p.getenv("GOFLAGS"); got != "-mod=mod -tags=x" {
+		t.Errorf("got GOFLAGS=%s, want the reconfigured one", got)
+	}
+	c := p.getCache()
+	c.RLock()
+	pkg := c.get("example.com/m/a")
+	c.RUnlock()
+	if pkg == nil || pkg.GetTypes().Scope().Lookup("X") == nil {
+		t.Error("the package is not loaded with the reconfigured environment")
+	}
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"go/token"
+	"go/types"
+
+	"github.com/sourcegraph/go-lsp"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// Symbol is a package level declaration of a package, a field or a method.
+type Symbol struct {
+	Name string
+	// Container is the type declaring a field or a method, Recv is the
+	// receiver of a method, with a "*" for a pointer receiver.
+	Container string
+	Recv      string
+	Kind      lsp.SymbolKind
+	Position  token.Position
+}
+
+// summary is what is kept of a package whose syntax and type information
+// are not in memory: its symbols and the positions of the objects it uses,
+// by objectKey. It is stored in the persistent index.
+type summary struct {
+	symbols    []Symbol
+	references map[string][]token.Position
+}
+
+// newSummary summarizes the package typ, type-checked from source with the
+// type information info. positions are the declarations of the objects of
+// typ in the syntax, when they differ from the positions of the objects.
+func newSummary(fset *token.FileSet, typ *types.Package, info *types.Info, positions map[types.Object]token.Pos) *summary {
+	s := &summary{
+		symbols:    packageSymbols(fset, typ, positions),
+		references: make(map[string][]token.Position),
+	}
+	for ident, obj := range info.Uses {
+		if key, ok := objectKey(obj); ok {
+			s.references[key] = append(s.references[key], fset.Position(ident.Pos()))
+		}
+	}
+	return s
+}
+
+// packageSymbols returns the package level symbols of typ, as the symbols
+// of its syntax are collected by the workspace symbol requests.
+func packageSymbols(fset *token.FileSet, typ *types.Package, positions map[types.Object]token.Pos) []Symbol {
+	var symbols []Symbol
+	add := func(obj types.Object, container, recv string, kind lsp.SymbolKind) {
+		pos, ok := positions[obj]
+		if !ok {
+			pos = obj.Pos()
+		}
+		symbols = append(symbols, Symbol{Name: obj.Name(), Container: container, Recv: recv, Kind: kind, Position: fset.Position(pos)})
+	}
+
+	scope := typ.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.TypeName:
+			switch t := obj.Type().Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < t.NumFields(); i++ {
+					if field := t.Field(i); !field.Anonymous() {
+						add(field, name, "", lsp.SKField)
+					}
+				}
+				add(obj, "", "", lsp.SKClass)
+			case *types.Interface:
+				for i := 0; i < t.NumExplicitMethods(); i++ {
+					add(t.ExplicitMethod(i), name, "", lsp.SKField)
+				}
+				add(obj, "", "", lsp.SKInterface)
+			default:
+				add(obj, "", "", lsp.SKClass)
+			}
+
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				method := named.Method(i)
+				recv := name
+				if _, ok := method.Type().(*types.Signature).Recv().Type().(*types.Pointer); ok {
+					recv = "*" + name
+				}
+				add(method, recv, recv, lsp.SKMethod)
+			}
+		case *types.Const:
+			add(obj, "", "", lsp.SKConstant)
+		case *types.Var:
+			add(obj, "", "", lsp.SKVariable)
+		case *types.Func:
+			add(obj, "", "", lsp.SKFunction)
+		}
+	}
+	return symbols
+}
+
+// objectKey returns the key of obj in the references of the summaries, which
+// identifies it across the type-checks of its package.
+func objectKey(obj types.Object) (string, bool) {
+	if obj.Pkg() == nil {
+		return "", false
+	}
+	path, err := objectpath.For(obj)
+	if err != nil {
+		return "", false
+	}
+	return obj.Pkg().Path() + "\x00" + string(path), true
+}
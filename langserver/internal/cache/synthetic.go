@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// syntheticDirName is the directory of the synthetic files standing for the
+// documents which aren't files, such as the untitled buffers of the editors.
+// It doesn't exist: their content is only in the overlay of the views, and
+// the go command skips the directories starting with a dot.
+const syntheticDirName = ".bingo-synthetic"
+
+// SyntheticFilename returns the synthetic file of the document named name. It
+// is type-checked as a single-file package whose imports are resolved in dir,
+// against the module or the GOPATH workspace of dir.
+func SyntheticFilename(dir, name string) string {
+	return filepath.Join(dir, syntheticDirName, name)
+}
+
+// isSyntheticFile reports whether filename is a synthetic file.
+func isSyntheticFile(filename string) bool {
+	return filepath.Base(filepath.Dir(filename)) == syntheticDirName
+}
+
+// syntheticMetadata loads the metadata of the single-file package of the
+// synthetic file f: the packages it imports are loaded from the directory
+// resolving them, the file itself is read from the overlay. It is called with
+// v.mu and v.mcache.mu held.
+func (v *View) syntheticMetadata(ctx context.Context, f *File, filename string) error {
+	f.read(ctx)
+	parsed, _ := parser.ParseFile(token.NewFileSet(), filename, f.content, parser.ImportsOnly)
+	if parsed == nil || parsed.Name == nil {
+		return fmt.Errorf("no package clause in %s", filename)
+	}
+
+	pkg := &packages.Package{
+		ID:              filepath.ToSlash(filename),
+		PkgPath:         filepath.ToSlash(filename),
+		Name:            parsed.Name.Name,
+		CompiledGoFiles: []string{filename},
+		Imports:         make(map[string]*packages.Package),
+	}
+	var importPaths []string
+	for _, spec := range parsed.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil && importPath != "C" {
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	if len(importPaths) > 0 {
+		cfg := v.Config
+		cfg.Mode = packages.LoadImports
+		cfg.Dir = filepath.Dir(filepath.Dir(filename))
+		cfg.Tests = false
+		if v.dirFlags != nil {
+			cfg.BuildFlags = v.dirFlags(cfg.Dir, cfg.BuildFlags)
+		}
+		imports, err := packages.Load(&cfg, importPaths...)
+		if err != nil {
+			return err
+		}
+		for _, imported := range imports {
+			pkg.Imports[imported.PkgPath] = imported
+		}
+	}
+
+	v.link(pkg.PkgPath, pkg, nil)
+	return nil
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/util"
+)
+
+// modulesTxt is the file listing the vendored modules of a module.
+const modulesTxt = "modules.txt"
+
+// goVersionRe matches the go directive of a go.mod file.
+var goVersionRe = regexp.MustCompile(`(?m)^go\s+1\.(\d+)`)
+
+// vendorEnabled reports whether the packages of the module rooted at dir are
+// loaded from its vendor directory with -mod=vendor: it has a
+// vendor/modules.txt file, and either the build flags or GOFLAGS ask for
+// -mod=vendor, or they don't set -mod and the go version of the go.mod file
+// is 1.14 or later, which vendors by default.
+func (p *Project) vendorEnabled(dir string, flags []string) bool {
+	if _, err := os.Stat(filepath.Join(dir, vendor, modulesTxt)); err != nil {
+		return false
+	}
+
+	mode := modFlag(strings.Fields(p.getenv("GOFLAGS")))
+	if flag := modFlag(flags); flag != "" {
+		mode = flag
+	}
+	if mode != "" {
+		return mode == vendor
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, gomod))
+	if err != nil {
+		return false
+	}
+	match := goVersionRe.FindSubmatch(content)
+	if match == nil {
+		return false
+	}
+	minor, _ := strconv.Atoi(string(match[1]))
+	return minor >= 14
+}
+
+// modFlag returns the value of the last -mod flag of flags, or "".
+func modFlag(flags []string) string {
+	mode := ""
+	for i, flag := range flags {
+		if strings.HasPrefix(flag, "-mod=") || strings.HasPrefix(flag, "--mod=") {
+			mode = flag[strings.Index(flag, "=")+1:]
+		} else if (flag == "-mod" || flag == "--mod") && i+1 < len(flags) {
+			mode = flags[i+1]
+		}
+	}
+	return mode
+}
+
+// withVendor returns flags with -mod=vendor, unless they set -mod.
+func withVendor(flags []string) []string {
+	if modFlag(flags) != "" {
+		return flags
+	}
+	return append(append([]string{}, flags...), "-mod=vendor")
+}
+
+// readVendoredModules returns the modules vendored in the module rooted at
+// dir, listed by its vendor/modules.txt file, indexed by their directory in
+// the vendor directory.
+func readVendoredModules(dir string) (map[string]moduleInfo, error) {
+	f, err := os.Open(filepath.Join(dir, vendor, modulesTxt))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	modules := make(map[string]moduleInfo)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// "# path version [=> replacement]" starts the packages of a module,
+		// "##" lines are annotations.
+		if !strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line[2:])
+		if len(fields) == 0 {
+			continue
+		}
+		module := moduleInfo{Path: fields[0], Dir: filepath.Join(dir, vendor, filepath.FromSlash(fields[0]))}
+		if len(fields) > 1 && fields[1] != "=>" {
+			module.Version = fields[1]
+		}
+		modules[util.LowerDriver(module.Dir)] = module
+	}
+	return modules, scanner.Err()
+}
+
+// isVendored reports whether the directory dir is a vendor directory of the
+// project or below one.
+func (p *Project) isVendored(dir string) bool {
+	dir = filepath.ToSlash(dir)
+	return p.isInsideProject(dir) && util.IsVendorDir(strings.TrimPrefix(dir, filepath.ToSlash(p.rootDir)))
+}
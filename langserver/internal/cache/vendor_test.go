@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVendorEnabled(t *testing.T) {
+	root, err := ioutil.TempDir("", "bingo-vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFile := func(name, content string) {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProject(context.Background(), nil, root, nil)
+	p.SetEnv([]string{"GOFLAGS="})
+	writeFile("go.mod", "module example.com/m\n\ngo 1.14\n")
+	if p.vendorEnabled(root, nil) {
+		t.Errorf("vendoring enabled without vendor/modules.txt")
+	}
+
+	writeFile("vendor/modules.txt", "# example.com/dep v1.0.0\n## explicit\nexample.com/dep\n# example.com/old v0.1.0 => ../old\nexample.com/old\n")
+	tests := []struct {
+		gomod   string
+		goflags string
+		flags   []string
+		want    bool
+	}{
+		{"go 1.14", "", nil, true},
+		{"go 1.16", "", nil, true},
+		{"go 1.13", "", nil, false},
+		{"", "", nil, false},
+		{"go 1.14", "", []string{"-mod=mod"}, false},
+		{"go 1.13", "", []string{"-mod", "vendor"}, true},
+		{"go 1.13", "-mod=vendor", nil, true},
+		{"go 1.14", "-mod=vendor", []string{"-mod=readonly"}, false},
+	}
+	for _, test := range tests {
+		writeFile("go.mod", "module example.com/m\n\n"+test.gomod+"\n")
+		p.SetEnv([]string{"GOFLAGS=" + test.goflags})
+		if got := p.vendorEnabled(root, test.flags); got != test.want {
+			t.Errorf("vendorEnabled with %q, GOFLAGS=%q and flags %v = %v, want %v", test.gomod, test.goflags, test.flags, got, test.want)
+		}
+	}
+
+	modules, err := readVendoredModules(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]moduleInfo{
+		filepath.Join(root, "vendor", "example.com", "dep"): {Path: "example.com/dep", Version: "v1.0.0", Dir: filepath.Join(root, "vendor", "example.com", "dep")},
+		filepath.Join(root, "vendor", "example.com", "old"): {Path: "example.com/old", Version: "v0.1.0", Dir: filepath.Join(root, "vendor", "example.com", "old")},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("readVendoredModules = %v, want %v", modules, want)
+	}
+
+	if !p.isVendored(filepath.Join(root, "vendor", "example.com", "dep")) {
+		t.Errorf("vendored package not reported as vendored")
+	}
+	if p.isVendored(filepath.Join(root, "pkg")) {
+		t.Errorf("package of the project reported as vendored")
+	}
+}
+
+func TestWithVendor(t *testing.T) {
+	if got, want := withVendor([]string{"-tags=a"}), []string{"-tags=a", "-mod=vendor"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withVendor = %v, want %v", got, want)
+	}
+	if got, want := withVendor([]string{"-mod=mod"}), []string{"-mod=mod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withVendor = %v, want %v", got, want)
+	}
+}
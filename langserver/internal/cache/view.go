@@ -46,8 +46,19 @@ type View struct {
 	// pcache caches type information for the packages of the opened files in a view.
 	pcache *packageCache
 
-	// gcache caches all package for project
-	gcache *GlobalCache
+	// gcache caches all package for project. It is set while holding both mu
+	// and gcacheMu, so that it can be read without waiting for a type-check.
+	gcacheMu sync.Mutex
+	gcache   *GlobalCache
+
+	// dirFlags returns the build flags of the packages of a directory, from
+	// the build flags of the view. It is nil when they are the same.
+	dirFlags func(dir string, flags []string) []string
+
+	// adhocDir reports whether the packages of a directory are loaded as
+	// ad-hoc packages with the environment of the view, see isAdhocDir. It
+	// is nil when none is.
+	adhocDir func(dir string, env []string) bool
 }
 
 type metadataCache struct {
@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"github.com/sourcegraph/go-lsp"
+)
+
+/**
+ * Completion item tags are extra annotations that tweak the rendering of a completion
+ * item.
+ */
+type CompletionItemTag int
+
+const (
+	/**
+	 * Render a completion as obsolete, usually using a strike-out.
+	 */
+	DeprecatedCompletion CompletionItemTag = 1
+)
+
+type CompletionItem struct {
+	lsp.CompletionItem
+
+	/**
+	 * Tags for this completion item.
+	 */
+	Tags []CompletionItemTag `json:"tags,omitempty"`
+
+	/**
+	 * Indicates if this item is deprecated.
+	 *
+	 * Kept for the clients which don't support completion item tags.
+	 */
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+/**
+ * Represents a collection of [completion items](#CompletionItem) to be presented
+ * in the editor.
+ */
+type CompletionList struct {
+	/**
+	 * This list it not complete. Further typing should result in recomputing
+	 * this list.
+	 */
+	IsIncomplete bool `json:"isIncomplete"`
+
+	/**
+	 * The completion items.
+	 */
+	Items []CompletionItem `json:"items"`
+}
@@ -0,0 +1,118 @@
+package protocol
+
+// ProgressToken is the token of a progress, a string or a number.
+type ProgressToken interface{}
+
+// WorkDoneProgressCreateParams are the parameters of the
+// window/workDoneProgress/create request.
+type WorkDoneProgressCreateParams struct {
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
+
+// WorkDoneProgressCancelParams are the parameters of the
+// window/workDoneProgress/cancel notification.
+type WorkDoneProgressCancelParams struct {
+	/**
+	 * The token to be used to report progress.
+	 */
+	Token ProgressToken `json:"token"`
+}
+
+// ProgressParams are the parameters of the $/progress notification.
+type ProgressParams struct {
+	/**
+	 * The progress token provided by the client or server.
+	 */
+	Token ProgressToken `json:"token"`
+
+	/**
+	 * The progress data.
+	 */
+	Value interface{} `json:"value"`
+}
+
+// WorkDoneProgressBegin starts a progress report.
+type WorkDoneProgressBegin struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Mandatory title of the progress operation. Used to briefly inform about
+	 * the kind of operation being performed.
+	 *
+	 * Examples: "Indexing" or "Linking dependencies".
+	 */
+	Title string `json:"title"`
+
+	/**
+	 * Controls if a cancel button should show to allow the user to cancel the
+	 * long running operation. Clients that don't support cancellation are allowed
+	 * to ignore the setting.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message. Contains
+	 * complementary information to the `title`.
+	 *
+	 * Examples: "3/25 files", "project/src/module2", "node_modules/some_dep".
+	 * If unset, the previous progress message (if any) is still valid.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 * If not provided infinite progress is assumed and clients are allowed
+	 * to ignore the `percentage` value in subsequent in report notifications.
+	 *
+	 * The value should be steadily rising. Clients are free to ignore values
+	 * that are not following this rule.
+	 */
+	Percentage int `json:"percentage"`
+}
+
+// WorkDoneProgressReport reports the advance of a progress.
+type WorkDoneProgressReport struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Controls enablement state of a cancel button. This property is only valid if a cancel
+	 * button got requested in the `WorkDoneProgressStart` payload.
+	 *
+	 * Clients that don't support cancellation or don't support control the button's
+	 * enablement state are allowed to ignore the setting.
+	 */
+	Cancellable bool `json:"cancellable,omitempty"`
+
+	/**
+	 * Optional, more detailed associated progress message. Contains
+	 * complementary information to the `title`.
+	 *
+	 * Examples: "3/25 files", "project/src/module2", "node_modules/some_dep".
+	 * If unset, the previous progress message (if any) is still valid.
+	 */
+	Message string `json:"message,omitempty"`
+
+	/**
+	 * Optional progress percentage to display (value 100 is considered 100%).
+	 * If not provided infinite progress is assumed and clients are allowed
+	 * to ignore the `percentage` value in subsequent in report notifications.
+	 *
+	 * The value should be steadily rising. Clients are free to ignore values
+	 * that are not following this rule.
+	 */
+	Percentage int `json:"percentage"`
+}
+
+// WorkDoneProgressEnd ends a progress report.
+type WorkDoneProgressEnd struct {
+	Kind string `json:"kind"`
+
+	/**
+	 * Optional, a final message indicating to for example indicate the outcome
+	 * of the operation.
+	 */
+	Message string `json:"message,omitempty"`
+}
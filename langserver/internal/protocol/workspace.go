@@ -0,0 +1,273 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// ClientCapabilities holds the client capabilities the server relies on which
+// are missing from lsp.ClientCapabilities.
+type ClientCapabilities struct {
+	Workspace struct {
+		/**
+		 * The client supports `workspace/configuration` requests.
+		 */
+		Configuration bool `json:"configuration,omitempty"`
+
+		/**
+		 * Capabilities specific to the `workspace/didChangeConfiguration` notification.
+		 */
+		DidChangeConfiguration struct {
+			/**
+			 * Did change configuration notification supports dynamic registration.
+			 */
+			DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+		} `json:"didChangeConfiguration,omitempty"`
+
+		/**
+		 * Capabilities specific to the `workspace/didChangeWatchedFiles` notification.
+		 */
+		DidChangeWatchedFiles struct {
+			/**
+			 * Did change watched files notification supports dynamic registration.
+			 */
+			DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+		} `json:"didChangeWatchedFiles,omitempty"`
+	} `json:"workspace,omitempty"`
+
+	Window struct {
+		/**
+		 * Whether client supports handling progress notifications.
+		 */
+		WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+	} `json:"window,omitempty"`
+}
+
+// Registration is a general parameter to register for a capability.
+type Registration struct {
+	/**
+	 * The id used to register the request. The id can be used to deregister
+	 * the request again.
+	 */
+	ID string `json:"id"`
+
+	/**
+	 * The method / capability to register for.
+	 */
+	Method string `json:"method"`
+
+	/**
+	 * Options necessary for the registration.
+	 */
+	RegisterOptions interface{} `json:"registerOptions,omitempty"`
+}
+
+// RegistrationParams are the parameters of the client/registerCapability
+// request.
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions describe options to be used when
+// registering for file system change events.
+type DidChangeWatchedFilesRegistrationOptions struct {
+	/**
+	 * The watchers to register.
+	 */
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// WatchKind is the kind of the file events a FileSystemWatcher is interested
+// in.
+type WatchKind int
+
+const (
+	/**
+	 * Interested in create events.
+	 */
+	WatchCreate WatchKind = 1
+
+	/**
+	 * Interested in change events
+	 */
+	WatchChange WatchKind = 2
+
+	/**
+	 * Interested in delete events
+	 */
+	WatchDelete WatchKind = 4
+)
+
+// FileSystemWatcher watches the files matching a glob pattern.
+type FileSystemWatcher struct {
+	/**
+	 * The  glob pattern to watch.
+	 *
+	 * Glob patterns can have the following syntax:
+	 * - `*` to match one or more characters in a path segment
+	 * - `?` to match on one character in a path segment
+	 * - `**` to match any number of path segments, including none
+	 * - `{}` to group conditions (e.g. `**​/*.{ts,js}` matches all TypeScript and JavaScript files)
+	 */
+	GlobPattern string `json:"globPattern"`
+
+	/**
+	 * The kind of events of interest. If omitted it defaults
+	 * to WatchKind.Create | WatchKind.Change | WatchKind.Delete
+	 * which is 7.
+	 */
+	Kind WatchKind `json:"kind,omitempty"`
+}
+
+// FileChangeType is the type of a file event.
+type FileChangeType int
+
+const (
+	/**
+	 * The file got created.
+	 */
+	Created FileChangeType = 1
+
+	/**
+	 * The file got changed.
+	 */
+	Changed FileChangeType = 2
+
+	/**
+	 * The file got deleted.
+	 */
+	Deleted FileChangeType = 3
+)
+
+// FileEvent describes a file change event.
+type FileEvent struct {
+	/**
+	 * The file's URI.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The change type.
+	 */
+	Type FileChangeType `json:"type"`
+}
+
+// DidChangeWatchedFilesParams are the parameters of the
+// workspace/didChangeWatchedFiles notification.
+type DidChangeWatchedFilesParams struct {
+	/**
+	 * The actual file events.
+	 */
+	Changes []FileEvent `json:"changes"`
+}
+
+// DidChangeConfigurationParams are the parameters of the
+// workspace/didChangeConfiguration notification.
+type DidChangeConfigurationParams struct {
+	/**
+	 * The actual changed settings
+	 */
+	Settings json.RawMessage `json:"settings"`
+}
+
+// DidChangeConfigurationRegistrationOptions describe options to be used when
+// registering for configuration change notifications.
+type DidChangeConfigurationRegistrationOptions struct {
+	/**
+	 * The configuration sections of interest.
+	 */
+	Section string `json:"section,omitempty"`
+}
+
+// ConfigurationItem is a configuration section asked to the client.
+type ConfigurationItem struct {
+	/**
+	 * The scope to get the configuration section for.
+	 */
+	ScopeURI lsp.DocumentURI `json:"scopeUri,omitempty"`
+
+	/**
+	 * The configuration section asked for.
+	 */
+	Section string `json:"section,omitempty"`
+}
+
+// ConfigurationParams are the parameters of the workspace/configuration
+// request.
+type ConfigurationParams struct {
+	Items []ConfigurationItem `json:"items"`
+}
+
+// WorkspaceFolder is a folder of the workspace opened by the client.
+type WorkspaceFolder struct {
+	/**
+	 * The associated URI for this workspace folder.
+	 */
+	URI lsp.DocumentURI `json:"uri"`
+
+	/**
+	 * The name of the workspace folder. Defaults to the
+	 * uri's basename.
+	 */
+	Name string `json:"name"`
+}
+
+// WorkspaceFoldersChangeEvent describes the workspace folders added and
+// removed by the client.
+type WorkspaceFoldersChangeEvent struct {
+	/**
+	 * The array of added workspace folders
+	 */
+	Added []WorkspaceFolder `json:"added"`
+
+	/**
+	 * The array of the removed workspace folders
+	 */
+	Removed []WorkspaceFolder `json:"removed"`
+}
+
+// DidChangeWorkspaceFoldersParams are the parameters of the
+// workspace/didChangeWorkspaceFolders notification.
+type DidChangeWorkspaceFoldersParams struct {
+	/**
+	 * The actual workspace folder change event.
+	 */
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+// ServerCapabilities are the capabilities of the server, with the ones
+// missing from lsp.ServerCapabilities.
+type ServerCapabilities struct {
+	lsp.ServerCapabilities
+
+	/**
+	 * Workspace specific server capabilities
+	 */
+	Workspace *WorkspaceServerCapabilities `json:"workspace,omitempty"`
+}
+
+// WorkspaceServerCapabilities are the workspace specific capabilities of the
+// server.
+type WorkspaceServerCapabilities struct {
+	WorkspaceFolders struct {
+		/**
+		 * The server has support for workspace folders
+		 */
+		Supported bool `json:"supported,omitempty"`
+
+		/**
+		 * Whether the server wants to receive workspace folder
+		 * change notifications.
+		 */
+		ChangeNotifications bool `json:"changeNotifications,omitempty"`
+	} `json:"workspaceFolders,omitempty"`
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	/**
+	 * The capabilities the language server provides.
+	 */
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
@@ -21,7 +21,7 @@ import (
 	"golang.org/x/tools/go/analysis"
 )
 
-func analyze(ctx context.Context, v View, pkgs []Package, analyzers []*analysis.Analyzer) []*Action {
+func analyze(ctx context.Context, fset *token.FileSet, pkgs []Package, analyzers []*analysis.Analyzer) []*Action {
 	// Build nodes for initial packages.
 	var roots []*Action
 	for _, a := range analyzers {
@@ -36,7 +36,7 @@ func analyze(ctx context.Context, v View, pkgs []Package, analyzers []*analysis.
 	}
 
 	// Execute the graph in parallel.
-	execAll(v.FileSet(), roots)
+	execAll(fset, roots)
 
 	return roots
 }
@@ -130,7 +130,8 @@ func NewInvalidNodeError(fset *token.FileSet, node ast.Node) *InvalidNodeError {
 }
 
 func GetObjectPathNode(pkg Package, fset *token.FileSet, o types.Object) (nodes []ast.Node, ident *ast.Ident, err error) {
-	nodes, _ = GetPathNodes(pkg, fset, o.Pos(), o.Pos())
+	pos := pkg.GetObjectPos(o)
+	nodes, _ = GetPathNodes(pkg, fset, pos, pos)
 	if len(nodes) == 0 {
 		ip := pkg.GetImport(o.Pkg().Path())
 		if ip == nil {
@@ -138,7 +139,8 @@ func GetObjectPathNode(pkg Package, fset *token.FileSet, o types.Object) (nodes
 			fmt.Errorf("import package %s of package %s does not exist", o.Pkg().Path(), pkg.GetPkgPath())
 		}
 
-		nodes, err = GetPathNodes(ip, fset, o.Pos(), o.Pos())
+		pos = ip.GetObjectPos(o)
+		nodes, err = GetPathNodes(ip, fset, pos, pos)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -19,6 +19,7 @@ type CompletionItem struct {
 	Kind          CompletionItemKind
 	Score         float64
 	Documentation string
+	Deprecated    bool
 }
 
 type CompletionItemKind int
@@ -114,12 +115,7 @@ func Completion(ctx context.Context, f File, pos token.Pos, cache Cache) (items
 				return isParameter(sig, v)
 			})
 
-			// TODO(mbana): figure out how to get `golang.org/x/tools/go/packages.Packages` from `go/types.Package`.
-			// pkg, ok := obj.Pkg().(pkg.GetTypes())
-			ok := true
-			if ok {
-				// FindComments(obj.Pkg(), obj, "")
-			}
+			item.Deprecated = ObjectDeprecation(pkg, obj) != ""
 
 			items = append(items, item)
 		}
@@ -336,6 +332,7 @@ func lexical(path []ast.Node, pos token.Pos, pkg *types.Package, info *types.Inf
 					comments, err := FindComments(p, p.GetFileSet(), obj, prefix)
 					if err == nil && len(items) > 1 {
 						items[itemIndex].Documentation = comments
+						items[itemIndex].Deprecated = Deprecation(comments) != ""
 					}
 				}
 			}
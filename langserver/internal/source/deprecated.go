@@ -0,0 +1,78 @@
+package source
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+const deprecatedPrefix = "Deprecated: "
+
+// Deprecation returns the notice of the "Deprecated: " paragraph of a doc
+// comment, or "" if the comment doesn't mark its object as deprecated.
+func Deprecation(comments string) string {
+	for _, paragraph := range strings.Split(comments, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if strings.HasPrefix(paragraph, deprecatedPrefix) {
+			return strings.Join(strings.Fields(paragraph[len(deprecatedPrefix):]), " ")
+		}
+	}
+	return ""
+}
+
+// ObjectDeprecation returns the deprecation notice of o, which is declared
+// in pkg or in one of the packages it imports, or "" if o isn't deprecated.
+// The notice is read from, and cached by, the package declaring o.
+func ObjectDeprecation(pkg Package, o types.Object) string {
+	if o == nil || o.Pkg() == nil {
+		return ""
+	}
+
+	owner := pkg
+	if o.Pkg() != pkg.GetTypes() {
+		if owner = pkg.GetImport(o.Pkg().Path()); owner == nil {
+			return ""
+		}
+	}
+	return owner.GetDeprecation(o, func() string {
+		comments, err := FindComments(owner, owner.GetFileSet(), o, o.Name())
+		if err != nil {
+			return ""
+		}
+		return Deprecation(comments)
+	})
+}
+
+// DeprecatedUses calls report for every identifier of pkg referring to a
+// deprecated object of another package, along with its deprecation notice.
+// The uses inside the package declaring the object, which maintains it, are
+// not reported.
+func DeprecatedUses(pkg Package, report func(ident *ast.Ident, o types.Object, notice string)) {
+	info := pkg.GetTypesInfo()
+	if info == nil {
+		return
+	}
+
+	notices := make(map[types.Object]string)
+	for _, file := range pkg.GetSyntax() {
+		ast.Inspect(file, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			o := info.Uses[ident]
+			if o == nil || o.Pkg() == nil || o.Pkg() == pkg.GetTypes() {
+				return true
+			}
+			notice, ok := notices[o]
+			if !ok {
+				notice = ObjectDeprecation(pkg, o)
+				notices[o] = notice
+			}
+			if notice != "" {
+				report(ident, o, notice)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDeprecation(t *testing.T) {
+	tests := []struct {
+		comments string
+		want     string
+	}{
+		{"", ""},
+		{"Foo does things.\n", ""},
+		{"Deprecated: use Bar.\n", "use Bar."},
+		{"Foo does things.\n\nDeprecated: use Bar\ninstead.\n", "use Bar instead."},
+		{"Foo does things.\nDeprecated: not a paragraph.\n", ""},
+		{"Foo is Deprecated: not at the start.\n", ""},
+	}
+
+	for _, test := range tests {
+		if got := Deprecation(test.comments); got != test.want {
+			t.Errorf("Deprecation(%q) = %q, want %q", test.comments, got, test.want)
+		}
+	}
+}
+
+// testPackage is a Package type-checked from source, counting the lookups of
+// the deprecation notices.
+type testPackage struct {
+	fset         *token.FileSet
+	filenames    []string
+	files        []*ast.File
+	types        *types.Package
+	info         *types.Info
+	imports      map[string]*testPackage
+	deprecations map[types.Object]string
+	lookups      int
+}
+
+func newTestPackage(t *testing.T, fset *token.FileSet, path, src string, imports ...*testPackage) *testPackage {
+	filename := path + ".go"
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &testPackage{
+		fset:         fset,
+		filenames:    []string{filename},
+		files:        []*ast.File{file},
+		info:         &types.Info{Defs: make(map[*ast.Ident]types.Object), Uses: make(map[*ast.Ident]types.Object)},
+		imports:      make(map[string]*testPackage),
+		deprecations: make(map[types.Object]string),
+	}
+	for _, ip := range imports {
+		pkg.imports[ip.GetPkgPath()] = ip
+	}
+	cfg := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		return pkg.imports[path].types, nil
+	})}
+	if pkg.types, err = cfg.Check(path, fset, pkg.files, pkg.info); err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func (pkg *testPackage) GetFilenames() []string      { return pkg.filenames }
+func (pkg *testPackage) GetSyntax() []*ast.File      { return pkg.files }
+func (pkg *testPackage) GetErrors() []packages.Error { return nil }
+func (pkg *testPackage) GetTypes() *types.Package    { return pkg.types }
+func (pkg *testPackage) GetTypesInfo() *types.Info   { return pkg.info }
+func (pkg *testPackage) IsIllTyped() bool            { return false }
+func (pkg *testPackage) GetPkgPath() string          { return pkg.types.Path() }
+func (pkg *testPackage) GetName() string             { return pkg.types.Name() }
+func (pkg *testPackage) GetFileSet() *token.FileSet  { return pkg.fset }
+func (pkg *testPackage) GetImport(path string) Package {
+	if ip, ok := pkg.imports[path]; ok {
+		return ip
+	}
+	return nil
+}
+func (pkg *testPackage) GetActionGraph(ctx context.Context, a *analysis.Analyzer) (*Action, error) {
+	return nil, nil
+}
+func (pkg *testPackage) GetDeprecation(o types.Object, notice func() string) string {
+	if n, ok := pkg.deprecations[o]; ok {
+		return n
+	}
+	pkg.lookups++
+	pkg.deprecations[o] = notice()
+	return pkg.deprecations[o]
+}
+func (pkg *testPackage) GetObjectPos(o types.Object) token.Pos { return o.Pos() }
+
+func TestDeprecatedUses(t *testing.T) {
+	// The packages have file sets of their own, as the packages of the
+	// cache loaded apart.
+	dep := newTestPackage(t, token.NewFileSet(), "dep", `package dep
+
+// Old does things.
+//
+// Deprecated: use New.
+func Old() {}
+
+func New() {}
+`)
+	pkg := newTestPackage(t, token.NewFileSet(), "pkg", `package pkg
+
+import "dep"
+
+// Local is deprecated as well.
+//
+// Deprecated: use dep.New.
+func Local() {}
+
+func f() {
+	dep.Old()
+	dep.Old()
+	dep.New()
+	Local()
+}
+`, dep)
+
+	var got []string
+	DeprecatedUses(pkg, func(ident *ast.Ident, o types.Object, notice string) {
+		got = append(got, fmt.Sprintf("%s: %s", ident.Name, notice))
+	})
+	// The use of Local in its own package is not reported.
+	want := []string{"Old: use New.", "Old: use New."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The notices are cached by the packages declaring the objects: Old and
+	// New in dep.
+	if dep.lookups != 2 || pkg.lookups != 0 {
+		t.Errorf("got %d lookups in dep and %d in pkg, want 2 and 0", dep.lookups, pkg.lookups)
+	}
+	ObjectDeprecation(pkg, dep.types.Scope().Lookup("Old"))
+	if dep.lookups != 2 {
+		t.Errorf("the notice of dep.Old was looked up again")
+	}
+	if notice := ObjectDeprecation(pkg, pkg.types.Scope().Lookup("Local")); notice != "use dep.New." {
+		t.Errorf("got notice %q for Local, want use dep.New.", notice)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"go/ast"
 	"go/format"
 	"strings"
+	"sync"
 
 	"github.com/saibing/bingo/langserver/internal/diff"
 	"github.com/saibing/bingo/langserver/internal/span"
@@ -55,9 +56,18 @@ func Format(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
 	return computeTextEdits(ctx, f, buf.String()), nil
 }
 
-// Imports formats a file using the goimports tool.
-func Imports(ctx context.Context, f File, rng span.Range) ([]TextEdit, error) {
+// importsMu guards imports.LocalPrefix, which is global to the imports
+// package.
+var importsMu sync.Mutex
+
+// Imports formats a file using the goimports tool. The imports starting with
+// localPrefix, a comma-separated list of prefixes, are grouped after the
+// third-party ones.
+func Imports(ctx context.Context, f File, rng span.Range, localPrefix string) ([]TextEdit, error) {
+	importsMu.Lock()
+	imports.LocalPrefix = localPrefix
 	formatted, err := imports.Process(f.GetToken(ctx).Name(), f.GetContent(ctx), nil)
+	importsMu.Unlock()
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +19,7 @@ import (
 // package. The view provides access to files and their contents, so the source
 // package does not directly access the file system.
 type View interface {
+	BackgroundContext() context.Context
 	GetFile(ctx context.Context, uri span.URI) (File, error)
 	SetContent(ctx context.Context, uri span.URI, content []byte) error
 	FileSet() *token.FileSet
@@ -51,6 +52,15 @@ type Package interface {
 	GetName() string
 	GetImport(pkgPath string) Package
 	GetFileSet() *token.FileSet
+
+	// GetDeprecation returns the deprecation notice of the object o of the
+	// package, computed by notice the first time.
+	GetDeprecation(o types.Object, notice func() string) string
+
+	// GetObjectPos returns the position of the declaration of the object o
+	// of the package in its syntax, which differs from o.Pos() for the
+	// objects read from export data.
+	GetObjectPos(o types.Object) token.Pos
 }
 
 // TextEdit represents a change to a section of a document.
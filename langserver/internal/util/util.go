@@ -140,3 +140,9 @@ func LowerDriver(path string) string {
 
 	return strings.ToLower(path[0:1]) + path[1:]
 }
+
+// IsVendorDir reports whether the slash-separated path is a vendor directory
+// or below one.
+func IsVendorDir(path string) bool {
+	return strings.HasPrefix(path, "vendor/") || strings.Contains(path, "/vendor/") || path == "vendor" || strings.HasSuffix(path, "/vendor")
+}
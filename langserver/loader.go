@@ -31,7 +31,7 @@ func (h *LangHandler) typeCheck(ctx context.Context, fileURI lsp.DocumentURI, po
 		return nil, pos, err
 	}
 
-	pkg, f, err := h.project.TypeCheck(ctx, fileURI)
+	pkg, f, err := h.projectFor(fileURI).TypeCheck(ctx, fileURI)
 	if err != nil {
 		return nil, pos, err
 	}
@@ -79,7 +79,7 @@ func (h *LangHandler) loadPackageAndAst(ctx context.Context, fileURI lsp.Documen
 		return nil, nil, err
 	}
 
-	pkg, f, err := h.project.TypeCheck(ctx, fileURI)
+	pkg, f, err := h.projectFor(fileURI).TypeCheck(ctx, fileURI)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/tools/go/packages/packagestest"
@@ -130,7 +131,8 @@ func (tx *TestContext) initServer(t *testing.T) {
 	// Prepare the connection.
 	client, server := net.Pipe()
 	tx.connServer = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(server, jsonrpc2.VSCodeObjectCodec{}), tx.h)
-	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), tx.h)
+	status := &statusHandler{Handler: tx.h, ready: make(chan struct{})}
+	tx.conn = jsonrpc2.NewConn(tx.ctx, jsonrpc2.NewBufferedStream(client, jsonrpc2.VSCodeObjectCodec{}), status)
 
 	tdCap := lsp.TextDocumentClientCapabilities{}
 	tdCap.Completion.CompletionItemKind.ValueSet = []lsp.CompletionItemKind{lsp.CIKConstant}
@@ -145,6 +147,29 @@ func (tx *TestContext) initServer(t *testing.T) {
 	if err := tx.conn.Call(tx.ctx, "initialize", params, nil); err != nil {
 		t.Fatal("conn.Call initialize:", err)
 	}
+
+	// The workspace is loaded in the background.
+	<-status.ready
+}
+
+// statusHandler closes ready once the server reports that the workspace is
+// done loading.
+type statusHandler struct {
+	jsonrpc2.Handler
+	ready chan struct{}
+	once  sync.Once
+}
+
+func (h *statusHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if req.Method != "bingo/status" {
+		h.Handler.Handle(ctx, conn, req)
+		return
+	}
+
+	var params StatusParams
+	if req.Params != nil && json.Unmarshal(*req.Params, &params) == nil && params.State != statusLoading {
+		h.once.Do(func() { close(h.ready) })
+	}
 }
 
 // tbRun calls (testing.T).Run or (testing.B).Run.
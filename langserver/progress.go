@@ -0,0 +1,198 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// progressInterval is the minimal interval between two progress reports.
+const progressInterval = 500 * time.Millisecond
+
+// progress reports the advance of a long running task to the client, with
+// window/workDoneProgress when the client supports it. Older clients get the
+// same reports as $/progress notifications of a token they didn't create, and
+// log messages.
+type progress struct {
+	ctx      context.Context
+	conn     jsonrpc2.JSONRPC2
+	title    string
+	total    int
+	token    protocol.ProgressToken
+	workDone bool
+	tokens   *progressTokens
+
+	mu   sync.Mutex
+	done int
+	last time.Time
+}
+
+// newProgress begins the report of the task title, made of total steps. The
+// task is cancellable from the client when cancel is not nil.
+func (h *LangHandler) newProgress(ctx context.Context, conn jsonrpc2.JSONRPC2, title string, total int, cancel context.CancelFunc) *progress {
+	p := &progress{ctx: ctx, conn: conn, title: title, total: total, tokens: &h.progressTokens}
+	p.token, p.workDone = p.tokens.create(ctx, conn, h.capabilities().Window.WorkDoneProgress)
+	if cancel != nil {
+		p.tokens.setCancel(p.token, cancel)
+	}
+
+	p.notify(ctx, &protocol.WorkDoneProgressBegin{Kind: "begin", Title: title, Cancellable: cancel != nil})
+	if !p.workDone {
+		p.log(ctx, fmt.Sprintf("%s: started", title))
+	}
+	return p
+}
+
+// step marks one more unit of work as done.
+func (p *progress) step(ctx context.Context) {
+	p.mu.Lock()
+	p.done++
+	done := p.done
+	report := time.Since(p.last) >= progressInterval || done == p.total
+	if report {
+		p.last = time.Now()
+	}
+	p.mu.Unlock()
+
+	if !report {
+		return
+	}
+
+	percentage := 100
+	if p.total > 0 {
+		percentage = done * 100 / p.total
+	}
+	p.report(ctx, fmt.Sprintf("%d/%d", done, p.total), percentage)
+}
+
+// Report implements cache.Progress.
+func (p *progress) Report(message string, percentage int) {
+	p.report(p.ctx, message, percentage)
+}
+
+func (p *progress) report(ctx context.Context, message string, percentage int) {
+	p.notify(ctx, &protocol.WorkDoneProgressReport{Kind: "report", Message: message, Percentage: percentage})
+	if !p.workDone {
+		p.log(ctx, fmt.Sprintf("%s: %s (%d%%)", p.title, message, percentage))
+	}
+}
+
+// End implements cache.Progress.
+func (p *progress) End(message string) {
+	p.end(p.ctx, message)
+}
+
+// end marks the task as finished.
+func (p *progress) end(ctx context.Context, message string) {
+	p.tokens.release(p.token)
+	p.notify(ctx, &protocol.WorkDoneProgressEnd{Kind: "end", Message: message})
+	if !p.workDone {
+		p.log(ctx, fmt.Sprintf("%s: %s", p.title, message))
+	}
+}
+
+func (p *progress) notify(ctx context.Context, value interface{}) {
+	_ = p.conn.Notify(ctx, "$/progress", &protocol.ProgressParams{Token: p.token, Value: value})
+}
+
+func (p *progress) log(ctx context.Context, message string) {
+	_ = p.conn.Notify(ctx, "window/logMessage", &lsp.LogMessageParams{Type: lsp.Info, Message: message})
+}
+
+// progressTokens hands out the tokens of the progress reports, and cancels
+// their tasks on window/workDoneProgress/cancel.
+type progressTokens struct {
+	mu sync.Mutex
+	// initialized is closed once the server may send requests to the
+	// client.
+	initialized chan struct{}
+	next        int
+	cancels     map[string]context.CancelFunc
+}
+
+// create returns a new token, and whether it is known to the client: it was
+// created by a window/workDoneProgress/create request when supported is set.
+// The tokens of the tasks started before the client is initialized, as the
+// initial load, are created once it is.
+func (t *progressTokens) create(ctx context.Context, conn jsonrpc2.JSONRPC2, supported bool) (protocol.ProgressToken, bool) {
+	t.mu.Lock()
+	t.next++
+	token := fmt.Sprintf("bingo-%d", t.next)
+	t.mu.Unlock()
+
+	if !supported {
+		return token, false
+	}
+	select {
+	case <-t.initializedChan():
+	case <-ctx.Done():
+		return token, false
+	}
+
+	if err := conn.Call(ctx, "window/workDoneProgress/create", &protocol.WorkDoneProgressCreateParams{Token: token}, nil); err != nil {
+		return token, false
+	}
+	return token, true
+}
+
+func (t *progressTokens) initializedChan() chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.initialized == nil {
+		t.initialized = make(chan struct{})
+	}
+	return t.initialized
+}
+
+func (t *progressTokens) setInitialized() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.initialized == nil {
+		t.initialized = make(chan struct{})
+	}
+	select {
+	case <-t.initialized:
+	default:
+		close(t.initialized)
+	}
+}
+
+// setCancel sets the function cancelling the task of token.
+func (t *progressTokens) setCancel(token protocol.ProgressToken, cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancels == nil {
+		t.cancels = make(map[string]context.CancelFunc)
+	}
+	t.cancels[fmt.Sprint(token)] = cancel
+}
+
+// release releases the context of the finished task of token.
+func (t *progressTokens) release(token protocol.ProgressToken) {
+	t.mu.Lock()
+	key := fmt.Sprint(token)
+	cancel := t.cancels[key]
+	delete(t.cancels, key)
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// cancel cancels the task of token.
+func (t *progressTokens) cancel(token protocol.ProgressToken) {
+	t.mu.Lock()
+	cancel := t.cancels[fmt.Sprint(token)]
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -0,0 +1,128 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// progressConn records the methods sent to the client, and refuses the
+// requests when refuse is set.
+type progressConn struct {
+	refuse bool
+
+	mu      sync.Mutex
+	methods []string
+	tokens  []protocol.ProgressToken
+}
+
+func (c *progressConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods = append(c.methods, method)
+	if c.refuse {
+		return errors.New("refused")
+	}
+	return nil
+}
+
+func (c *progressConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods = append(c.methods, method)
+	if p, ok := params.(*protocol.ProgressParams); ok {
+		c.tokens = append(c.tokens, p.Token)
+	}
+	return nil
+}
+
+func (c *progressConn) Close() error { return nil }
+
+func (c *progressConn) sent() ([]string, []protocol.ProgressToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.methods...), append([]protocol.ProgressToken(nil), c.tokens...)
+}
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		supported bool
+		refuse    bool
+		want      []string
+	}{
+		{"supported", true, false, []string{"window/workDoneProgress/create", "$/progress", "$/progress", "$/progress"}},
+		{"refused", true, true, []string{"window/workDoneProgress/create", "$/progress", "window/logMessage", "$/progress", "window/logMessage", "$/progress", "window/logMessage"}},
+		{"unsupported", false, false, []string{"$/progress", "window/logMessage", "$/progress", "window/logMessage", "$/progress", "window/logMessage"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &LangHandler{}
+			h.clientCapabilities.Window.WorkDoneProgress = test.supported
+			h.progressTokens.setInitialized()
+			conn := &progressConn{refuse: test.refuse}
+			p := h.newProgress(context.Background(), conn, "Loading packages", 1, nil)
+			p.step(context.Background())
+			p.End("1 packages")
+
+			methods, tokens := conn.sent()
+			if len(methods) != len(test.want) {
+				t.Fatalf("got %v, want %v", methods, test.want)
+			}
+			for i := range methods {
+				if methods[i] != test.want[i] {
+					t.Errorf("got %v, want %v", methods, test.want)
+					break
+				}
+			}
+			for _, token := range tokens {
+				if token != p.token {
+					t.Errorf("got token %v, want %v", token, p.token)
+				}
+			}
+		})
+	}
+}
+
+func TestProgressWaitsForInitialized(t *testing.T) {
+	t.Parallel()
+
+	h := &LangHandler{}
+	h.clientCapabilities.Window.WorkDoneProgress = true
+	conn := &progressConn{}
+
+	// The initial load begins its report before the client is initialized,
+	// the token is only created once it is.
+	created := make(chan *progress)
+	go func() {
+		created <- h.newProgress(context.Background(), conn, "Loading packages", 0, nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if methods, _ := conn.sent(); len(methods) != 0 {
+		t.Fatalf("got %v before initialized", methods)
+	}
+	h.progressTokens.setInitialized()
+
+	select {
+	case p := <-created:
+		if !p.workDone {
+			t.Error("the token is not created once the client is initialized")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the report doesn't begin once the client is initialized")
+	}
+	methods, _ := conn.sent()
+	if len(methods) != 2 || methods[0] != "window/workDoneProgress/create" || methods[1] != "$/progress" {
+		t.Errorf("got %v, want the creation of the token and the beginning of the report", methods)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
-	"go/token"
 	"go/types"
 
 	"github.com/saibing/bingo/langserver/internal/cache"
@@ -64,23 +63,23 @@ func (h *LangHandler) doHandleTextDocumentReferences(ctx context.Context, conn j
 		}
 	}
 
-	refs, err := h.findReferences(ctx, obj)
+	refs, err := h.findReferences(ctx, h.projectFor(params.TextDocument.URI), obj)
 	if err != nil {
 		// If we are canceled, cancel loop early
 		return nil, err
 	}
 
 	if params.Context.IncludeDeclaration {
-		refs = append(refs, &ast.Ident{NamePos: obj.Pos(), Name: obj.Name()})
+		refs = append(refs, goRangeToLSPLocation(pkg.GetFileSet(), obj.Pos(), obj.Name()))
 	}
 
-	return refStreamAndCollect(pkg.GetFileSet(), refs, params.Context.XLimit), nil
+	return refStreamAndCollect(refs, params.Context.XLimit), nil
 }
 
 // refStreamAndCollect returns all refs read in from chan until it is
 // closed. While it is reading, it will also occasionally stream out updates of
 // the refs received so far.
-func refStreamAndCollect(fset *token.FileSet, refs []*ast.Ident, limit int) []lsp.Location {
+func refStreamAndCollect(refs []lsp.Location, limit int) []lsp.Location {
 	if limit == 0 {
 		// If we don't have a limit, just set it to a value we should never exceed
 		limit = len(refs)
@@ -95,8 +94,7 @@ func refStreamAndCollect(fset *token.FileSet, refs []*ast.Ident, limit int) []ls
 
 	seen := map[string]bool{}
 	for i := 0; i < l; i++ {
-		n := refs[i]
-		loc := goRangeToLSPLocation(fset, n.Pos(), n.Name)
+		loc := refs[i]
 		if loc.URI == "" {
 			continue
 		}
@@ -117,11 +115,12 @@ func formatLocation(loc lsp.Location) string {
 	return fmt.Sprintf("%s:%s", loc.URI, loc.Range)
 }
 
-// findReferences will find all references to obj. It will only return
-// references from packages in pkg.Imports.
-func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object) ([]*ast.Ident, error) {
+// findReferences will find all references to obj in project. It will only
+// return references from packages in pkg.Imports. The references of the
+// packages whose syntax is not in memory are read from their summary.
+func (h *LangHandler) findReferences(ctx context.Context, project *cache.Project, queryObj types.Object) ([]lsp.Location, error) {
 	// Bail out early if the context is canceled
-	var refs []*ast.Ident
+	var refs []lsp.Location
 	var defPkgPath string
 	if queryObj.Pkg() != nil {
 		defPkgPath = queryObj.Pkg().Path()
@@ -140,20 +139,29 @@ func (h *LangHandler) findReferences(ctx context.Context, queryObj types.Object)
 			}
 		}
 
+		if cachePkg, ok := pkg.(*cache.Package); ok {
+			if positions, ok := cachePkg.GetReferences(queryObj); ok {
+				for _, position := range positions {
+					refs = append(refs, positionToLSPLocation(position, queryObj.Name()))
+				}
+				return nil
+			}
+		}
+
 		if pkg.GetTypesInfo() == nil {
 			return nil
 		}
 
 		for id, obj := range pkg.GetTypesInfo().Uses {
 			if sameObj(queryObj, obj) {
-				refs = append(refs, id)
+				refs = append(refs, goRangeToLSPLocation(pkg.GetFileSet(), id.Pos(), id.Name))
 			}
 		}
 
 		return nil
 	}
 
-	err := h.project.Search(f)
+	err := project.Search(f)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,110 @@
+package langserver
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/saibing/bingo/langserver/internal/span"
+)
+
+// reverseDiagnosticsDelay is how long an edited package has to stay quiet
+// before its importers are type-checked again.
+const reverseDiagnosticsDelay = 500 * time.Millisecond
+
+// reverseDiagnostics refreshes the diagnostics of the packages importing an
+// edited package, so that callers broken by an API change are reported
+// without reopening their files. Runs are debounced per package directory and
+// a newer edit cancels the run still in flight for the same directory.
+type reverseDiagnostics struct {
+	overlay   *overlay
+	debouncer *debouncer
+
+	mu     sync.Mutex
+	depth  int
+	budget time.Duration
+}
+
+func newReverseDiagnostics(overlay *overlay, debouncer *debouncer, depth int, budget time.Duration) *reverseDiagnostics {
+	return &reverseDiagnostics{
+		overlay:   overlay,
+		debouncer: debouncer,
+		depth:     depth,
+		budget:    budget,
+	}
+}
+
+// setLimits changes the depth and the budget of the runs scheduled from now
+// on.
+func (r *reverseDiagnostics) setLimits(depth int, budget time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.depth = depth
+	r.budget = budget
+}
+
+// schedule queues a refresh of the importers of the package containing uri.
+func (r *reverseDiagnostics) schedule(uri span.URI) {
+	if r == nil {
+		return
+	}
+
+	filename, err := uri.Filename()
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	depth, budget := r.depth, r.budget
+	r.mu.Unlock()
+	if depth <= 0 {
+		return
+	}
+
+	r.debouncer.schedule("reverse:"+filepath.Dir(filename), reverseDiagnosticsDelay, func() (context.Context, func(context.Context)) {
+		ctx, versions := r.overlay.snapshot(uri)
+		return ctx, func(ctx context.Context) {
+			if budget > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, budget)
+				defer cancel()
+			}
+			r.run(ctx, uri, depth, versions)
+		}
+	})
+}
+
+// run publishes the diagnostics of each file of the importers of the package
+// of uri. The importers are type-checked without opening their files.
+func (r *reverseDiagnostics) run(ctx context.Context, uri span.URI, depth int, versions map[string]int) {
+	project := r.overlay.projectFor(uri)
+	f, err := r.overlay.viewFor(uri).GetFile(ctx, uri)
+	if err != nil {
+		return
+	}
+	pkg := f.GetPackage(ctx)
+	if pkg == nil || pkg.GetPkgPath() == "" {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, dep := range project.ReverseDependencies(pkg.GetPkgPath(), depth) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		filenames := dep.GetFilenames()
+		if len(filenames) == 0 || seen[filenames[0]] {
+			continue
+		}
+		seen[filenames[0]] = true
+
+		checked, err := project.CheckPackage(ctx, dep)
+		if err != nil || ctx.Err() != nil {
+			continue
+		}
+		config := r.overlay.configFor(span.FileURI(filenames[0]))
+		r.overlay.publishDiagnostics(ctx, configurationDiagnostics(ctx, project, checked, config, r.overlay.configurations), versions)
+	}
+}
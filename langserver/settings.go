@@ -0,0 +1,166 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// configurationSection is the section of the settings of the client holding
+// the options of the server, in the InitializationOptions format.
+const configurationSection = "bingo"
+
+// registerConfiguration asks the client to notify the changes of its
+// settings, when it supports the dynamic registration of the notification.
+func (h *LangHandler) registerConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2) {
+	if !h.capabilities().Workspace.DidChangeConfiguration.DynamicRegistration {
+		return
+	}
+
+	params := protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:              "workspace/didChangeConfiguration",
+			Method:          "workspace/didChangeConfiguration",
+			RegisterOptions: protocol.DidChangeConfigurationRegistrationOptions{Section: configurationSection},
+		}},
+	}
+	if err := conn.Call(ctx, "client/registerCapability", params, nil); err != nil {
+		log.Printf("failed to register configuration changes: %s", err)
+	}
+}
+
+// fetchConfiguration pulls the settings of the client with a
+// workspace/configuration request, and applies them.
+func (h *LangHandler) fetchConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2) error {
+	params := protocol.ConfigurationParams{Items: []protocol.ConfigurationItem{{Section: configurationSection}}}
+	var settings []json.RawMessage
+	if err := conn.Call(ctx, "workspace/configuration", params, &settings); err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	return h.applySettings(ctx, conn, settings[0])
+}
+
+// handleDidChangeConfiguration applies the new settings of the client. They
+// are pulled again when the client supports workspace/configuration, since
+// the notification may not carry them.
+func (h *LangHandler) handleDidChangeConfiguration(ctx context.Context, conn jsonrpc2.JSONRPC2, params protocol.DidChangeConfigurationParams) error {
+	if h.capabilities().Workspace.Configuration {
+		return h.fetchConfiguration(ctx, conn)
+	}
+	return h.applySettings(ctx, conn, params.Settings)
+}
+
+// applySettings makes the options of the settings of the client, on top of
+// DefaultConfig, the configuration files and InitializationOptions, the
+// active configuration.
+func (h *LangHandler) applySettings(ctx context.Context, conn jsonrpc2.JSONRPC2, settings json.RawMessage) error {
+	options, err := settingsOptions(settings)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	layers := h.overlay.getLayers()
+	layers.settings = options
+	h.setLayers(ctx, conn, layers)
+	return nil
+}
+
+// settingsOptions decodes the options of the server from the settings of the
+// client: either the options themselves, or an object holding them under
+// configurationSection. No settings yield nil options.
+func settingsOptions(settings json.RawMessage) (*InitializationOptions, error) {
+	if len(settings) == 0 || string(settings) == "null" {
+		return nil, nil
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(settings, &sections); err != nil {
+		return nil, err
+	}
+	if section, ok := sections[configurationSection]; ok {
+		if string(section) == "null" {
+			return nil, nil
+		}
+		settings = section
+	}
+
+	var options InitializationOptions
+	if err := json.Unmarshal(settings, &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// setLayers replaces the sources of the active configuration with layers.
+// The options read by the requests apply at once. A change of the build tags
+// or of the global cache style of a project type-checks it again in the
+// background, as a change of the environment variables. The other options
+// are read when a project is created, so they apply to the workspace folders
+// opened from now on. It is called with h.mu held.
+func (h *LangHandler) setLayers(ctx context.Context, conn jsonrpc2.JSONRPC2, layers configLayers) {
+	oldLayers := h.overlay.getLayers()
+	if reflect.DeepEqual(oldLayers, layers) {
+		return
+	}
+
+	folders := h.folders.list()
+	olds := make([]*Config, len(folders))
+	for i, folder := range folders {
+		olds[i] = h.overlay.folderConfig(folder)
+	}
+	old := h.getConfig()
+	h.overlay.setLayers(layers)
+	config := h.getConfig()
+
+	for i, folder := range folders {
+		folderConfig := h.overlay.folderConfig(folder)
+		flags := buildFlags(folderConfig)
+		if !reflect.DeepEqual(buildFlags(olds[i]), flags) || !reflect.DeepEqual(goEnv(olds[i]), goEnv(folderConfig)) || olds[i].GlobalCacheStyle != folderConfig.GlobalCacheStyle {
+			folder.project.Reconfigure(flags, goEnv(folderConfig), cache.CacheStyle(folderConfig.GlobalCacheStyle))
+		} else if oldLayers.setsBuildTags() != layers.setsBuildTags() {
+			// The build tags of the modules are overridden, or not anymore.
+			folder.project.Reload()
+		}
+	}
+
+	if changed := projectOptionsChanged(old, config); len(changed) > 0 {
+		message := fmt.Sprintf("the change of %s applies to the workspace folders opened from now on", strings.Join(changed, ", "))
+		_ = conn.Notify(ctx, "window/showMessage", &lsp.ShowMessageParams{Type: lsp.Info, Message: message})
+	}
+}
+
+// projectOptionsChanged returns the names of the options read when a project
+// is created which differ between old and config.
+func projectOptionsChanged(old, config *Config) []string {
+	var changed []string
+	check := func(name string, before, after interface{}) {
+		if !reflect.DeepEqual(before, after) {
+			changed = append(changed, name)
+		}
+	}
+	check("indexDirectory", old.IndexDirectory, config.IndexDirectory)
+	check("includePatterns", old.IncludePatterns, config.IncludePatterns)
+	check("excludePatterns", old.ExcludePatterns, config.ExcludePatterns)
+	check("maxDepth", old.MaxDepth, config.MaxDepth)
+	check("useIgnoreFiles", old.UseIgnoreFiles, config.UseIgnoreFiles)
+	check("dependencyMode", old.DependencyMode, config.DependencyMode)
+	check("memoryLimit", old.MemoryLimit, config.MemoryLimit)
+	check("watchMode", old.WatchMode, config.WatchMode)
+	check("pollInterval", old.PollInterval, config.PollInterval)
+	check("goBinary", old.GoBinary, config.GoBinary)
+	return changed
+}
@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		settings string
+		want     []string
+	}{
+		{`{"bingo": {"buildTags": ["integration"]}}`, []string{"integration"}},
+		{`{"buildTags": ["integration"]}`, []string{"integration"}},
+		{`{"bingo": null}`, nil},
+		{`null`, nil},
+		{``, nil},
+	}
+
+	for _, test := range tests {
+		options, err := settingsOptions(json.RawMessage(test.settings))
+		if err != nil {
+			t.Errorf("settingsOptions(%q): %v", test.settings, err)
+			continue
+		}
+		var got []string
+		if options != nil {
+			got = options.BuildTags
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("settingsOptions(%q) build tags = %v, want %v", test.settings, got, test.want)
+		}
+	}
+
+	if _, err := settingsOptions(json.RawMessage(`["bingo"]`)); err == nil {
+		t.Errorf("settingsOptions accepted an array")
+	}
+}
+
+func TestProjectOptionsChanged(t *testing.T) {
+	t.Parallel()
+
+	old := NewDefaultConfig()
+	config := old
+	config.FormatStyle = goimportsStyle
+	config.BuildTags = []string{"integration"}
+	config.Env = map[string]string{"GOFLAGS": "-mod=vendor"}
+	if changed := projectOptionsChanged(&old, &config); len(changed) != 0 {
+		t.Errorf("got %v for options not deferred to the new folders, want none", changed)
+	}
+
+	config.MaxDepth = 2
+	config.DependencyMode = "export"
+	config.GoBinary = "/opt/go/bin/go"
+	if got, want := projectOptionsChanged(&old, &config), []string{"maxDepth", "dependencyMode", "goBinary"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -16,7 +16,7 @@ func (h *LangHandler) handleTextDocumentSignatureHelp(ctx context.Context, conn
 		return nil, err
 	}
 
-	f, err := h.View().GetFile(ctx, span.FromDocumentURI(fileURI))
+	f, err := h.viewFor(fileURI).GetFile(ctx, span.FromDocumentURI(fileURI))
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +26,7 @@ func (h *LangHandler) handleTextDocumentSignatureHelp(ctx context.Context, conn
 	}
 
 	pos := fromProtocolPosition(tok, params.Position)
-	info, err := source.SignatureHelp(ctx, f, pos, h.project.GetBuiltinPackage(), h.DefaultConfig.EnhanceSignatureHelp)
+	info, err := source.SignatureHelp(ctx, f, pos, h.projectFor(fileURI).GetBuiltinPackage(), h.configFor(fileURI).EnhanceSignatureHelp)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,47 @@
+package langserver
+
+import (
+	"context"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// The states of the workspace reported by the bingo/status notification.
+const (
+	// statusLoading is reported while the workspace is loaded in the
+	// background. The requests are answered from the opened files and the
+	// packages loaded so far.
+	statusLoading = "loading"
+	// statusReady is reported once the whole workspace is loaded.
+	statusReady = "ready"
+	// statusError is reported when the workspace failed to load.
+	statusError = "error"
+)
+
+// StatusParams are the parameters of the bingo/status notification, sent
+// when the state of the workspace changes.
+type StatusParams struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+func notifyStatus(ctx context.Context, conn jsonrpc2.JSONRPC2, state, message string) {
+	_ = conn.Notify(ctx, "bingo/status", &StatusParams{State: state, Message: message})
+}
+
+// PartialSymbolInformation is a workspace/symbol result computed while the
+// workspace is loading, which may miss the symbols of the packages not loaded
+// yet.
+type PartialSymbolInformation struct {
+	lsp.SymbolInformation
+	Partial bool `json:"partial"`
+}
+
+func partialSymbols(symbols []lsp.SymbolInformation) []PartialSymbolInformation {
+	partial := make([]PartialSymbolInformation, len(symbols))
+	for i, symbol := range symbols {
+		partial[i] = PartialSymbolInformation{SymbolInformation: symbol, Partial: true}
+	}
+	return partial
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/saibing/bingo/langserver/internal/cache"
 	"github.com/saibing/bingo/langserver/internal/source"
 	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
@@ -229,7 +230,7 @@ func score(q Query, s symbolPair) (scor int) {
 			scor += 3
 		}
 	}
-	if scor > 0 && !(strings.HasPrefix(filename, "vendor/") || strings.Contains(filename, "/vendor/")) {
+	if scor > 0 && !util.IsVendorDir(filename) {
 		// boost for non-vendor symbols
 		scor += 5
 	}
@@ -243,6 +244,11 @@ func score(q Query, s symbolPair) (scor int) {
 // toSym returns a SymbolInformation value derived from values we get
 // from visiting the Go ast.
 func toSym(name string, pkg source.Package, container string, recv string, kind lsp.SymbolKind, fs *token.FileSet, pos token.Pos) symbolPair {
+	return toSymbolPair(name, pkg, container, recv, kind, goRangeToLSPLocation(fs, pos, name))
+}
+
+// toSymbolPair returns the symbol name of pkg at loc.
+func toSymbolPair(name string, pkg source.Package, container string, recv string, kind lsp.SymbolKind, loc lsp.Location) symbolPair {
 	var id string
 	if container == "" {
 		id = fmt.Sprintf("%s/-/%s", path.Clean(pkg.GetPkgPath()), name)
@@ -254,12 +260,12 @@ func toSym(name string, pkg source.Package, container string, recv string, kind
 		SymbolInformation: lsp.SymbolInformation{
 			Name:          name,
 			Kind:          kind,
-			Location:      goRangeToLSPLocation(fs, pos, name),
+			Location:      loc,
 			ContainerName: container,
 		},
 		// NOTE: fields must be kept in sync with workspace_refs.go:defSymbolDescriptor
 		desc: symbolDescriptor{
-			Vendor:      false,
+			Vendor:      isVendorPackage(pkg),
 			Package:     path.Clean(pkg.GetPkgPath()),
 			PackageName: pkg.GetName(),
 			Recv:        recv,
@@ -346,7 +352,7 @@ func (h *LangHandler) handleSymbol(ctx context.Context, conn jsonrpc2.JSONRPC2,
 		return nil
 	}
 
-	err := h.project.Search(f)
+	err := h.search(f)
 	if err != nil {
 		return nil, err
 	}
@@ -474,6 +480,18 @@ func (c *SymbolCollector) Visit(n ast.Node) (w ast.Visitor) {
 }
 
 func astPkgToSymbols(pkg source.Package) []symbolPair {
+	// The packages restored from the index or evicted from memory are
+	// summarized, rather than loaded again.
+	if cachePkg, ok := pkg.(*cache.Package); ok {
+		if symbols, ok := cachePkg.GetSymbols(); ok {
+			pkgSyms := make([]symbolPair, 0, len(symbols))
+			for _, s := range symbols {
+				pkgSyms = append(pkgSyms, toSymbolPair(s.Name, pkg, s.Container, s.Recv, s.Kind, positionToLSPLocation(s.Position, s.Name)))
+			}
+			return pkgSyms
+		}
+	}
+
 	var pkgSyms []symbolPair
 	symbolCollector := &SymbolCollector{pkgSyms, pkg, pkg.GetFileSet()}
 
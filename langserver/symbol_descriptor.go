@@ -1,6 +1,10 @@
 package langserver
 
 import (
+	"path/filepath"
+
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/util"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/go-lsp/lspext"
 )
@@ -20,6 +24,17 @@ type symbolDescriptor struct {
 	Vendor      bool   `json:"vendor"`
 }
 
+// isVendorPackage reports whether pkg is vendored: its package path or the
+// directory of its files is in a vendor directory. The path of the packages
+// vendored in module mode doesn't include the vendor directory.
+func isVendorPackage(pkg source.Package) bool {
+	if util.IsVendorDir(pkg.GetPkgPath()) {
+		return true
+	}
+	filenames := pkg.GetFilenames()
+	return len(filenames) > 0 && util.IsVendorDir(filepath.ToSlash(filepath.Dir(filenames[0])))
+}
+
 // Contains ensures that b is a subset of our symbolDescriptor
 func (a *symbolDescriptor) Contains(b lspext.SymbolDescriptor) bool {
 	for k, v := range b {
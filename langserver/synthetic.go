@@ -0,0 +1,203 @@
+package langserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/util"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// syntheticDocuments are the opened documents whose URI isn't a file URI,
+// such as the untitled buffers of the editors. Each of them stands for a
+// synthetic file, see cache.SyntheticFilename, which the requests on the
+// document are served for.
+type syntheticDocuments struct {
+	mu sync.RWMutex
+	// files maps the URIs of the documents to the URIs of their synthetic
+	// files, documents maps them back.
+	files     map[lsp.DocumentURI]lsp.DocumentURI
+	documents map[lsp.DocumentURI]lsp.DocumentURI
+}
+
+func newSyntheticDocuments() *syntheticDocuments {
+	return &syntheticDocuments{
+		files:     make(map[lsp.DocumentURI]lsp.DocumentURI),
+		documents: make(map[lsp.DocumentURI]lsp.DocumentURI),
+	}
+}
+
+// open returns the URI of the synthetic file of the document uri, which is
+// created in dir if the document isn't opened yet.
+func (d *syntheticDocuments) open(uri lsp.DocumentURI, dir string) lsp.DocumentURI {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if file, ok := d.files[uri]; ok {
+		return file
+	}
+	name := syntheticName(uri)
+	file := util.PathToURI(cache.SyntheticFilename(dir, name+".go"))
+	for i := 2; d.documents[file] != ""; i++ {
+		file = util.PathToURI(cache.SyntheticFilename(dir, fmt.Sprintf("%s_%d.go", name, i)))
+	}
+	d.files[uri] = file
+	d.documents[file] = uri
+	return file
+}
+
+// close forgets the document uri.
+func (d *syntheticDocuments) close(uri lsp.DocumentURI) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.documents, d.files[uri])
+	delete(d.files, uri)
+}
+
+// file returns the URI of the synthetic file of the opened document uri, or
+// "".
+func (d *syntheticDocuments) file(uri lsp.DocumentURI) lsp.DocumentURI {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.files[uri]
+}
+
+// document returns the URI of the document of the synthetic file fileURI, or
+// fileURI itself if it isn't a synthetic file.
+func (d *syntheticDocuments) document(fileURI lsp.DocumentURI) lsp.DocumentURI {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if uri, ok := d.documents[fileURI]; ok {
+		return uri
+	}
+	return fileURI
+}
+
+// syntheticName returns the name of the synthetic file of the document uri,
+// without extension: its scheme and path, with the characters unsafe in a
+// file name replaced.
+func syntheticName(uri lsp.DocumentURI) string {
+	name := strings.TrimSuffix(strings.Replace(string(uri), ":", "-", 1), ".go")
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	// The go command ignores the files whose name starts with _ or .
+	return strings.TrimLeft(name, "_.-")
+}
+
+// syntheticDir returns the directory resolving the imports of the synthetic
+// files, see Config.SyntheticDirectory.
+func (h *LangHandler) syntheticDir() string {
+	dir := h.getConfig().SyntheticDirectory
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	// The outermost folders come last.
+	folders := h.folders.list()
+	if len(folders) == 0 {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(folders[len(folders)-1].rootDir, dir)
+}
+
+// toSyntheticFile returns the request req on the synthetic file of its
+// document, when the document isn't a file, and the URI of the document. The
+// document is opened on textDocument/didOpen. The other requests are left
+// alone.
+func (h *LangHandler) toSyntheticFile(req *jsonrpc2.Request) (*jsonrpc2.Request, lsp.DocumentURI, error) {
+	if req.Params == nil || !strings.HasPrefix(req.Method, "textDocument/") {
+		return req, "", nil
+	}
+
+	var params struct {
+		TextDocument struct {
+			URI lsp.DocumentURI `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return req, "", nil
+	}
+	uri := params.TextDocument.URI
+	if uri == "" || util.IsURI(uri) {
+		return req, "", nil
+	}
+
+	file := h.overlay.synthetic.file(uri)
+	if file == "" {
+		if req.Method != "textDocument/didOpen" {
+			return req, "", nil
+		}
+		file = h.overlay.synthetic.open(uri, h.syntheticDir())
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(*req.Params, &fields); err != nil {
+		return nil, "", err
+	}
+	var textDocument map[string]json.RawMessage
+	if err := json.Unmarshal(fields["textDocument"], &textDocument); err != nil {
+		return nil, "", err
+	}
+	var err error
+	if textDocument["uri"], err = json.Marshal(file); err != nil {
+		return nil, "", err
+	}
+	if fields["textDocument"], err = json.Marshal(textDocument); err != nil {
+		return nil, "", err
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	synthetic := *req
+	synthetic.Params = (*json.RawMessage)(&data)
+	return &synthetic, uri, nil
+}
+
+// fromSyntheticFiles returns result with the URIs of the synthetic files
+// replaced by the ones of their documents.
+func (d *syntheticDocuments) fromSyntheticFiles(result interface{}) (interface{}, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return d.replaceFiles(value), nil
+}
+
+// replaceFiles replaces the URIs of the synthetic files in the strings and
+// the object keys of the decoded JSON value.
+func (d *syntheticDocuments) replaceFiles(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		return string(d.document(lsp.DocumentURI(value)))
+	case []interface{}:
+		for i := range value {
+			value[i] = d.replaceFiles(value[i])
+		}
+		return value
+	case map[string]interface{}:
+		replaced := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			replaced[string(d.document(lsp.DocumentURI(key)))] = d.replaceFiles(field)
+		}
+		return replaced
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSyntheticName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[lsp.DocumentURI]string{
+		"untitled:Untitled-1":         "untitled-Untitled-1",
+		"untitled:/scratch/main.go":   "untitled-_scratch_main",
+		"vscode-notebook-cell:a b#c1": "vscode-notebook-cell-a_b_c1",
+	}
+	for uri, want := range tests {
+		if got := syntheticName(uri); got != want {
+			t.Errorf("syntheticName(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestSyntheticDocuments(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{SyntheticDirectory: "/ws/scratch"}
+	h := &LangHandler{HandlerShared: &HandlerShared{overlay: &overlay{config: config, synthetic: newSyntheticDocuments()}}, folders: newWorkspaceFolders()}
+	request := func(method string, uri lsp.DocumentURI) *jsonrpc2.Request {
+		params := json.RawMessage(`{"textDocument": {"uri": "` + uri + `", "version": 1}, "position": {"line": 1, "character": 2}}`)
+		return &jsonrpc2.Request{Method: method, Params: &params}
+	}
+
+	if req, document, err := h.toSyntheticFile(request("textDocument/hover", "untitled:Untitled-1")); err != nil || document != "" || req.Method != "textDocument/hover" {
+		t.Fatalf("got document %q and error %v for a document not opened", document, err)
+	}
+
+	req, document, err := h.toSyntheticFile(request("textDocument/didOpen", "untitled:Untitled-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document != "untitled:Untitled-1" {
+		t.Errorf("got document %q, want untitled:Untitled-1", document)
+	}
+	var params struct {
+		TextDocument lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+		Position     lsp.Position                        `json:"position"`
+	}
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	file := lsp.DocumentURI("file:///ws/scratch/.bingo-synthetic/untitled-Untitled-1.go")
+	if params.TextDocument.URI != file || params.TextDocument.Version != 1 || params.Position.Character != 2 {
+		t.Errorf("got params %+v, want the same on %s", params, file)
+	}
+
+	// Another document with the same name gets its own file.
+	if got, want := h.overlay.synthetic.open("untitled:Untitled_1", "/ws/scratch"), lsp.DocumentURI("file:///ws/scratch/.bingo-synthetic/untitled-Untitled_1.go"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := h.overlay.synthetic.open("untitled:Untitled:1", "/ws/scratch"), lsp.DocumentURI("file:///ws/scratch/.bingo-synthetic/untitled-Untitled_1_2.go"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	result := map[string]interface{}{
+		"changes": map[lsp.DocumentURI][]lsp.TextEdit{file: {{NewText: "x"}}},
+		"uri":     file,
+		"line":    12,
+	}
+	got, err := h.overlay.synthetic.fromSyntheticFiles(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(got)
+	want := `{"changes":{"untitled:Untitled-1":[{"newText":"x","range":{"end":{"character":0,"line":0},"start":{"character":0,"line":0}}}]},"line":12,"uri":"untitled:Untitled-1"}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	h.overlay.synthetic.close("untitled:Untitled-1")
+	if got := h.overlay.synthetic.document(file); got != file {
+		t.Errorf("closed document still maps %s to %s", file, got)
+	}
+	if got := h.overlay.synthetic.file("untitled:Untitled-1"); got != "" {
+		t.Errorf("closed document still has a synthetic file")
+	}
+}
@@ -0,0 +1,17 @@
+package langserver
+
+import (
+	"encoding/json"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlToJSON converts a TOML document to JSON, so that the .toml
+// configuration files decode like the .json ones.
+func tomlToJSON(data []byte) ([]byte, error) {
+	doc := make(map[string]interface{})
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,55 @@
+package langserver
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTOMLToJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		toml string
+		want string
+	}{
+		{``, `{}`},
+		{`buildTags = ["integration", 'e2e'] # comment`, `{"buildTags":["integration","e2e"]}`},
+		{"maxDepth = 4\nuseIgnoreFiles = true\npollInterval = 1_000", `{"maxDepth":4,"pollInterval":1000,"useIgnoreFiles":true}`},
+		{"goimportsLocalPrefix = \"github.com/a\\tb\"", `{"goimportsLocalPrefix":"github.com/a\tb"}`},
+		{"excludePatterns = [\n  \"vendor\",\n  \"testdata\", # generated\n]", `{"excludePatterns":["vendor","testdata"]}`},
+		{"[modules.\"tools/gen\"]\nbuildTags = [\"tools\"]\n\n[modules.cmd]\nformatStyle = \"goimports\"", `{"modules":{"cmd":{"formatStyle":"goimports"},"tools/gen":{"buildTags":["tools"]}}}`},
+		{"[modules.api]\nbuildTags = []", `{"modules":{"api":{"buildTags":[]}}}`},
+		{"[[buildConfigurations]]\ngoos = \"windows\"\n[[buildConfigurations]]\ngoos = \"darwin\"", `{"buildConfigurations":[{"goos":"windows"},{"goos":"darwin"}]}`},
+		{`modules = { api = { buildTags = ["a"] } }`, `{"modules":{"api":{"buildTags":["a"]}}}`},
+	}
+
+	for _, test := range tests {
+		got, err := tomlToJSON([]byte(test.toml))
+		if err != nil {
+			t.Errorf("tomlToJSON(%q): %v", test.toml, err)
+			continue
+		}
+		if !jsonEqual(t, got, []byte(test.want)) {
+			t.Errorf("tomlToJSON(%q) = %s, want %s", test.toml, got, test.want)
+		}
+	}
+
+	for _, toml := range []string{`maxDepth = `, `buildTags = ["a"`, `formatStyle = "gofmt`, `maxDepth = 1 2`, `[modules`, `maxDepth = four`} {
+		if _, err := tomlToJSON([]byte(toml)); err == nil {
+			t.Errorf("tomlToJSON(%q) succeeded, want an error", toml)
+		}
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	xs, _ := json.Marshal(x)
+	ys, _ := json.Marshal(y)
+	return string(xs) == string(ys)
+}
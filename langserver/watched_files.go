@@ -0,0 +1,86 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// watchedFilesPatterns are the glob patterns of the files whose changes
+// affect the packages of the workspace, and of the configuration files.
+var watchedFilesPatterns = []string{"**/*.go", "**/go.mod", "**/go.sum", "**/.bingo.json", "**/.bingo.toml"}
+
+// clientWatchesFiles reports whether the client can watch the files of the
+// workspace for the server, through dynamic registration.
+func clientWatchesFiles(caps protocol.ClientCapabilities) bool {
+	return caps.Workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// registerWatchedFiles asks the client to report the changes of the files of
+// the workspace with workspace/didChangeWatchedFiles. The internal file system
+// watcher is started if the client refuses.
+func (h *LangHandler) registerWatchedFiles(ctx context.Context, conn jsonrpc2.JSONRPC2) {
+	if !clientWatchesFiles(h.capabilities()) {
+		return
+	}
+
+	var watchers []protocol.FileSystemWatcher
+	for _, pattern := range watchedFilesPatterns {
+		watchers = append(watchers, protocol.FileSystemWatcher{GlobPattern: pattern})
+	}
+
+	params := protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:              "workspace/didChangeWatchedFiles",
+			Method:          "workspace/didChangeWatchedFiles",
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+		}},
+	}
+
+	if err := conn.Call(ctx, "client/registerCapability", params, nil); err != nil {
+		log.Printf("failed to register watched files, fall back to fsnotify: %s", err)
+		h.mu.Lock()
+		h.watchFallback = true
+		h.mu.Unlock()
+		for _, project := range h.projects() {
+			project.Watch()
+		}
+	}
+}
+
+func (h *LangHandler) handleDidChangeWatchedFiles(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request) error {
+	if req.Params == nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+	}
+	var params protocol.DidChangeWatchedFilesParams
+	if err := json.Unmarshal(*req.Params, &params); err != nil {
+		return err
+	}
+
+	// The changes are dispatched to the projects of the folders of the files.
+	var projects []*cache.Project
+	filenames := make(map[*cache.Project][]string)
+	for _, change := range params.Changes {
+		if isConfigFile(h.FilePath(change.URI)) {
+			h.didChangeConfigFile(ctx, conn, h.FilePath(change.URI))
+			continue
+		}
+		project := h.projectFor(change.URI)
+		if project == nil {
+			continue
+		}
+		if _, ok := filenames[project]; !ok {
+			projects = append(projects, project)
+		}
+		filenames[project] = append(filenames[project], h.FilePath(change.URI))
+	}
+
+	for _, project := range projects {
+		project.DidChangeFiles(filenames[project])
+	}
+	return nil
+}
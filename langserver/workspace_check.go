@@ -0,0 +1,456 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/saibing/bingo/langserver/internal/cache"
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/saibing/bingo/langserver/internal/source"
+	"github.com/saibing/bingo/langserver/internal/span"
+	"github.com/saibing/bingo/langserver/internal/util"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// checkWorkspaceCommand is the workspace/executeCommand command which
+// type-checks and analyzes every package of the workspace.
+const checkWorkspaceCommand = "bingo.checkWorkspace"
+
+// cacheStatsCommand is the workspace/executeCommand command which returns
+// the memory statistics of the global cache.
+const cacheStatsCommand = "bingo.cacheStats"
+
+// CheckSummary is the result of a workspace check.
+type CheckSummary struct {
+	Packages int `json:"packages"`
+	Files    int `json:"files"`
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+}
+
+func (h *LangHandler) handleExecuteCommand(ctx context.Context, conn jsonrpc2.JSONRPC2, req *jsonrpc2.Request, params lsp.ExecuteCommandParams) (interface{}, error) {
+	switch params.Command {
+	case checkWorkspaceCommand:
+		return h.checkWorkspace(ctx, conn)
+	case cacheStatsCommand:
+		return h.cacheStats(), nil
+	default:
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("command not supported: %s", params.Command)}
+	}
+}
+
+// checkWorkspace publishes the diagnostics of every workspace package in the
+// global cache, once it is loaded. Packages are type-checked through the
+// view, so that the content of opened documents is taken into account.
+func (h *LangHandler) checkWorkspace(ctx context.Context, conn jsonrpc2.JSONRPC2) (*CheckSummary, error) {
+	var pkgs []source.Package
+	projects := make(map[source.Package]*cache.Project)
+	for _, project := range h.projects() {
+		select {
+		case <-project.Ready():
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		projectPkgs, err := workspacePackages(project, func(source.Package) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range projectPkgs {
+			projects[pkg] = project
+		}
+		pkgs = append(pkgs, projectPkgs...)
+	}
+
+	versions := h.overlay.documentVersions()
+	p := h.newProgress(ctx, conn, "check workspace", len(pkgs), nil)
+	reports := map[string][]protocol.Diagnostic{}
+	for _, pkg := range pkgs {
+		if ctx.Err() != nil {
+			p.end(ctx, "cancelled")
+			return nil, ctx.Err()
+		}
+
+		project := projects[pkg]
+		fileURI := span.FileURI(pkg.GetFilenames()[0])
+		f, err := project.ViewFor(fileURI).GetFile(ctx, fileURI)
+		if err == nil {
+			if fresh := f.GetPackage(ctx); fresh != nil {
+				pkg = fresh
+			}
+		}
+		mergeReports(reports, configurationDiagnostics(ctx, project, pkg, h.overlay.configFor(fileURI), nil))
+		p.step(ctx)
+	}
+
+	h.overlay.publishDiagnostics(ctx, reports, versions)
+	summary := summarize(reports, len(pkgs))
+	p.end(ctx, fmt.Sprintf("%d errors, %d warnings in %d packages", summary.Errors, summary.Warnings, summary.Packages))
+	return summary, nil
+}
+
+// cacheStats returns the statistics of the global caches of the projects.
+// The heap is shared by the projects.
+func (h *LangHandler) cacheStats() cache.CacheStats {
+	var stats cache.CacheStats
+	for i, project := range h.projects() {
+		projectStats := project.CacheStats()
+		if i == 0 {
+			stats = projectStats
+			continue
+		}
+		stats.Packages += projectStats.Packages
+		stats.Loaded += projectStats.Loaded
+		stats.Evictions += projectStats.Evictions
+		stats.Reloads += projectStats.Reloads
+	}
+	return stats
+}
+
+// workspacePackages returns the packages of the global cache which belong to
+// the project and are accepted by match. Test variants sharing their first
+// file with an already returned package are skipped.
+func workspacePackages(project *cache.Project, match func(source.Package) bool) ([]source.Package, error) {
+	var pkgs []source.Package
+	seen := map[string]bool{}
+	err := project.Search(func(pkg source.Package) error {
+		filenames := pkg.GetFilenames()
+		if len(filenames) == 0 || !project.IsInside(filenames[0]) || !match(pkg) {
+			return nil
+		}
+
+		key := pkg.GetPkgPath() + " " + strings.Join(filenames, " ")
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		pkgs = append(pkgs, pkg)
+		return nil
+	})
+	return pkgs, err
+}
+
+// mergeReports adds the diagnostics of src to dst, dropping duplicates which
+// are reported once for a package and once for its test variant.
+func mergeReports(dst, src map[string][]protocol.Diagnostic) {
+	for filename, diagnostics := range src {
+		existing, ok := dst[filename]
+		if !ok {
+			existing = []protocol.Diagnostic{}
+		}
+		for _, d := range diagnostics {
+			duplicate := false
+			for _, e := range existing {
+				if e.Range == d.Range && e.Message == d.Message && e.Source == d.Source {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				existing = append(existing, d)
+			}
+		}
+		dst[filename] = existing
+	}
+}
+
+func summarize(reports map[string][]protocol.Diagnostic, packages int) *CheckSummary {
+	summary := &CheckSummary{Packages: packages, Files: len(reports)}
+	for _, diagnostics := range reports {
+		for _, d := range diagnostics {
+			switch d.Severity {
+			case lsp.Error:
+				summary.Errors++
+			case lsp.Warning:
+				summary.Warnings++
+			}
+		}
+	}
+	return summary
+}
+
+// Check type-checks and analyzes the packages under rootDir matching
+// patterns, and writes their diagnostics to w in the given format (text, json
+// or sarif). It computes the same diagnostics as the editor, with the
+// analyzers named by the analyses option. As in the editor, no diagnostic is
+// suppressed. It reads the configuration files of rootDir and of its modules
+// on top of cfg, as a workspace folder opened at rootDir. The returned
+// summary tells how many errors were found.
+func Check(ctx context.Context, cfg Config, rootDir string, patterns []string, format string, w io.Writer) (*CheckSummary, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	layers := configLayers{defaults: cfg}
+	folder := newWorkspaceFolder(util.LowerDriver(rootDir))
+	if _, err := folder.readConfigFile(folder.rootDir); err != nil {
+		return nil, err
+	}
+	cfg = layers.config(folder.rootOptions()...)
+
+	project := cache.NewProject(ctx, logConn{}, rootDir, buildFlags(&cfg))
+	if err := project.SetGoBinary(cfg.GoBinary); err != nil {
+		return nil, err
+	}
+	project.SetEnv(goEnv(&cfg))
+	project.SetIndexDir(cfg.IndexDirectory)
+	project.SetWalkOptions(walkOptions(&cfg))
+	project.SetDependencyMode(cache.DependencyMode(cfg.DependencyMode))
+	project.SetModuleTags(folder.moduleTags)
+	folder.project = project
+	if err := project.Init(ctx, cache.Always); err != nil {
+		return nil, err
+	}
+
+	pkgs, err := workspacePackages(project, func(pkg source.Package) bool {
+		dir := filepath.Dir(pkg.GetFilenames()[0])
+		for _, pattern := range patterns {
+			if matchPattern(rootDir, pattern, dir, pkg.GetPkgPath()) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[string][]protocol.Diagnostic{}
+	for _, pkg := range pkgs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		config := layers.config(folder.fileOptions(util.LowerDriver(filepath.Dir(pkg.GetFilenames()[0])))...)
+		mergeReports(reports, configurationDiagnostics(ctx, project, pkg, &config, nil))
+	}
+
+	if err := writeReports(w, format, rootDir, reports); err != nil {
+		return nil, err
+	}
+	return summarize(reports, len(pkgs)), nil
+}
+
+// matchPattern reports whether the package in dir with import path pkgPath
+// matches a go command line pattern, either a directory pattern relative to
+// rootDir such as ./... or an import path pattern such as example.com/x/....
+func matchPattern(rootDir, pattern, dir, pkgPath string) bool {
+	recursive := strings.HasSuffix(pattern, "...")
+	base := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+
+	if build.IsLocalImport(pattern) || filepath.IsAbs(pattern) {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(rootDir, base)
+		}
+		base = filepath.Clean(base)
+		return dir == base || recursive && strings.HasPrefix(dir, base+string(filepath.Separator))
+	}
+
+	return pkgPath == base || recursive && (base == "" || strings.HasPrefix(pkgPath, base+"/"))
+}
+
+// checkDiagnostic is a diagnostic flattened for the text and json outputs.
+type checkDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+}
+
+func flattenReports(rootDir string, reports map[string][]protocol.Diagnostic) []checkDiagnostic {
+	var result []checkDiagnostic
+	for filename, diagnostics := range reports {
+		if rel, err := filepath.Rel(rootDir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			filename = rel
+		}
+		for _, d := range diagnostics {
+			result = append(result, checkDiagnostic{
+				File:      filepath.ToSlash(filename),
+				Line:      d.Range.Start.Line + 1,
+				Column:    d.Range.Start.Character + 1,
+				EndLine:   d.Range.End.Line + 1,
+				EndColumn: d.Range.End.Character + 1,
+				Severity:  severityName(d.Severity),
+				Source:    d.Source,
+				Message:   d.Message,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return result
+}
+
+func severityName(severity lsp.DiagnosticSeverity) string {
+	switch severity {
+	case lsp.Error:
+		return "error"
+	case lsp.Information:
+		return "info"
+	case lsp.Hint:
+		return "hint"
+	default:
+		return "warning"
+	}
+}
+
+func writeReports(w io.Writer, format string, rootDir string, reports map[string][]protocol.Diagnostic) error {
+	diagnostics := flattenReports(rootDir, reports)
+
+	switch format {
+	case "", "text":
+		for _, d := range diagnostics {
+			if _, err := fmt.Fprintf(w, "%s:%d:%d: %s: %s (%s)\n", d.File, d.Line, d.Column, d.Severity, d.Message, d.Source); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "json":
+		if diagnostics == nil {
+			diagnostics = []checkDiagnostic{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diagnostics)
+
+	case "sarif":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(toSarif(diagnostics))
+
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+}
+
+// The types below are the subset of the SARIF 2.1.0 format needed to report
+// diagnostics to code scanning tools.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func toSarif(diagnostics []checkDiagnostic) *sarifLog {
+	results := []sarifResult{}
+	for _, d := range diagnostics {
+		level := d.Severity
+		if level == "info" || level == "hint" {
+			level = "note"
+		}
+		results = append(results, sarifResult{
+			RuleID:  d.Source,
+			Level:   level,
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: d.File},
+					Region: sarifRegion{
+						StartLine:   d.Line,
+						StartColumn: d.Column,
+						EndLine:     d.EndLine,
+						EndColumn:   d.EndColumn,
+					},
+				},
+			}},
+		})
+	}
+
+	return &sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "bingo", InformationURI: "https://github.com/saibing/bingo"}},
+			Results: results,
+		}},
+	}
+}
+
+// logConn is a jsonrpc2.JSONRPC2 used when bingo runs without a client. The
+// notifications sent to the client are written to the log instead.
+type logConn struct{}
+
+func (logConn) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	return fmt.Errorf("%s is not supported without a client", method)
+}
+
+func (logConn) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	switch p := params.(type) {
+	case *lsp.LogMessageParams:
+		log.Println(p.Message)
+	case *lsp.ShowMessageParams:
+		log.Println(p.Message)
+	}
+	return nil
+}
+
+func (logConn) Close() error {
+	return nil
+}
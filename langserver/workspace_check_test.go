@@ -0,0 +1,144 @@
+package langserver
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/saibing/bingo/langserver/internal/protocol"
+	"github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		dir     string
+		pkgPath string
+		want    bool
+	}{
+		{"./...", "/root", "example.com/root", true},
+		{"./...", "/root/a/b", "example.com/root/a/b", true},
+		{"./a", "/root/a", "example.com/root/a", true},
+		{"./a", "/root/a/b", "example.com/root/a/b", false},
+		{"./a/...", "/root/a/b", "example.com/root/a/b", true},
+		{"./a/...", "/root/ab", "example.com/root/ab", false},
+		{"/root/a/...", "/root/a", "example.com/root/a", true},
+		{"example.com/root/a/...", "/root/a/b", "example.com/root/a/b", true},
+		{"example.com/root/a", "/root/a/b", "example.com/root/a/b", false},
+		{"...", "/root/a", "example.com/root/a", true},
+	}
+
+	for _, test := range tests {
+		got := matchPattern("/root", test.pattern, test.dir, test.pkgPath)
+		if got != test.want {
+			t.Errorf("matchPattern(%q, %q, %q) = %t, want %t", test.pattern, test.dir, test.pkgPath, got, test.want)
+		}
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pos := func(line, char int) lsp.Range {
+		return lsp.Range{Start: lsp.Position{Line: line, Character: char}, End: lsp.Position{Line: line, Character: char}}
+	}
+	reports := map[string][]protocol.Diagnostic{
+		"/root/b/b.go": {{Diagnostic: lsp.Diagnostic{Range: pos(7, 23), Severity: lsp.Warning, Source: "printf", Message: "bad format"}}},
+		"/root/a/a.go": {
+			{Diagnostic: lsp.Diagnostic{Range: pos(4, 0), Severity: lsp.Error, Source: "LSP: Go compiler", Message: "undeclared name: x"}},
+			{Diagnostic: lsp.Diagnostic{Range: pos(1, 2), Severity: lsp.Error, Source: "LSP: Go compiler", Message: "missing return"}},
+		},
+	}
+	mergeReports(reports, map[string][]protocol.Diagnostic{
+		"/root/b/b.go": {{Diagnostic: lsp.Diagnostic{Range: pos(7, 23), Severity: lsp.Warning, Source: "printf", Message: "bad format"}}},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(writeReports(&buf, "text", "/root", reports))
+	require.Equal("a/a.go:2:3: error: missing return (LSP: Go compiler)\n"+
+		"a/a.go:5:1: error: undeclared name: x (LSP: Go compiler)\n"+
+		"b/b.go:8:24: warning: bad format (printf)\n", buf.String())
+
+	summary := summarize(reports, 2)
+	require.Equal(2, summary.Errors)
+	require.Equal(1, summary.Warnings)
+
+	require.Error(writeReports(&buf, "xml", "/root", reports))
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "bingo-check")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"go.mod":      "module example.com/m\n",
+		"a/a.go":      "package a\n",
+		"a/x.go":      "// +build x\n\npackage a\n\nvar _ int = \"x\"\n",
+		".bingo.toml": "buildTags = [\"x\"]\n\n[env]\nGO111MODULE = \"on\"\nGOFLAGS = \"-mod=mod\"\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(content), 0644))
+	}
+
+	// The file is only type-checked with the build tags of the
+	// configuration file.
+	cfg := NewDefaultConfig()
+	cfg.IndexDirectory = "none"
+	var buf bytes.Buffer
+	summary, err := Check(context.Background(), cfg, root, nil, "text", &buf)
+	require.NoError(err)
+	require.Equal(1, summary.Errors, buf.String())
+	require.Contains(buf.String(), "a/x.go:5:")
+}
+
+func TestCheckAnalyses(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "bingo-check")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"go.mod": "module example.com/m\n",
+		"a/a.go": "package a\n\nimport \"fmt\"\n\nfunc F() {\n\tfmt.Printf(\"%d\", \"x\")\n}\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(ioutil.WriteFile(filename, []byte(content), 0644))
+	}
+
+	cfg := NewDefaultConfig()
+	cfg.IndexDirectory = "none"
+	cfg.Env = map[string]string{"GO111MODULE": "on", "GOFLAGS": "-mod=mod"}
+	check := func(analyses ...string) (*CheckSummary, string) {
+		cfg := cfg
+		cfg.Analyses = analyses
+		var buf bytes.Buffer
+		summary, err := Check(context.Background(), cfg, root, nil, "text", &buf)
+		require.NoError(err)
+		return summary, buf.String()
+	}
+
+	// No analyzer runs unless the configuration names it, as in the
+	// editor.
+	summary, out := check()
+	require.Equal(0, summary.Warnings, out)
+
+	summary, out = check("printf")
+	require.Equal(1, summary.Warnings, out)
+	require.Contains(out, "a/a.go:6:14: warning:")
+	require.Contains(out, "(printf)")
+}
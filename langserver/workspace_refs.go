@@ -66,7 +66,7 @@ func (h *LangHandler) handleWorkspaceReferences(ctx context.Context, conn jsonrp
 		return err
 	}
 
-	err := h.project.Search(f)
+	err := h.search(f)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +101,7 @@ func (h *LangHandler) workspaceRefsFromPkg(ctx context.Context, conn jsonrpc2.JS
 		Info:     pkg.GetTypesInfo(),
 	}
 	refsErr := cfg.Refs(func(r *refs.Ref) {
-		symDesc, err := defSymbolDescriptor(pkg, h.project, r.Def, findPackage)
+		symDesc, err := defSymbolDescriptor(pkg, h.projectOf(pkg), r.Def, findPackage)
 		if err != nil {
 			// Log the error, and flag it as one in the trace -- but do not
 			// halt execution (hopefully, it is limited to a small subset of
@@ -147,7 +147,7 @@ func defSymbolDescriptor(pkg source.Package, project *cache.Project, def refs.De
 
 	// NOTE: fields must be kept in sync with symbol.go:symbolEqual
 	desc := &symbolDescriptor{
-		Vendor:      false,
+		Vendor:      isVendorPackage(defPkg),
 		Package:     defPkg.GetPkgPath(),
 		PackageName: def.PackageName,
 		Recv:        "",
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
@@ -31,12 +32,29 @@ var (
 
 	// Default Config, can be overridden by InitializationOptions
 	diagnosticsStyle     = flag.String("diagnostics-style", "instant", "diagnostics style: none, instant, onsave. Can be overridden by InitializationOptions.")
+	diagnosticsDelay     = flag.Duration("diagnostics-delay", 200*time.Millisecond, "how long a package has to stay unchanged before its diagnostics are computed again. Can be overridden by InitializationOptions.")
 	disableFuncSnippet   = flag.Bool("disable-func-snippet", false, "disable argument snippets on func completion. Can be overridden by InitializationOptions.")
 	globalCacheStyle     = flag.String("cache-style", "always", "set global cache style: none, on-demand, always. Can be overridden by InitializationOptions.")
+	indexDir             = flag.String("index-dir", "", "directory of the persistent package indexes, none disables them. Defaults to the user cache directory. Can be overridden by InitializationOptions.")
+	includePatterns      = flag.String("include", "", "globs of the directories whose go.mod files and packages are loaded, separated by commas. Defaults to all. Can be overridden by InitializationOptions.")
+	excludePatterns      = flag.String("exclude", "", "globs of the directories skipped when walking, watching and loading the workspace, separated by commas. Defaults to .git,.svn,.hg,.vscode,.idea,node_modules,vendor. Can be overridden by InitializationOptions.")
+	maxDepth             = flag.Int("max-depth", 8, "maximum depth of the directories of the workspace walked for go.mod files and watched, 0 disables the limit. The packages below it are loaded all the same. Can be overridden by InitializationOptions.")
+	useIgnoreFiles       = flag.Bool("use-ignore-files", false, "skip the directories ignored by .gitignore and .ignore files. Can be overridden by InitializationOptions.")
+	dependencyMode       = flag.String("dependency-mode", "source", "how the dependencies outside of the workspace are loaded: source, or export which reads their compiled export data and loads their source on demand. Can be overridden by InitializationOptions.")
+	memoryLimit          = flag.Int("memory-limit", 0, "heap size in megabytes above which the least recently used packages of the global cache are evicted from memory, 0 disables the limit. Can be overridden by InitializationOptions.")
+	watchMode            = flag.String("watch-mode", "auto", "how files are watched when the client doesn't watch them: fsnotify, poll, or auto which polls once the file system watches are exhausted. Can be overridden by InitializationOptions.")
+	pollInterval         = flag.Duration("poll-interval", 2*time.Second, "how often files are polled for changes in poll watch mode. Can be overridden by InitializationOptions.")
 	formatStyle          = flag.String("format-style", "goimports", "which format style is used to format documents. Supported: gofmt and goimports. Can be overridden by InitializationOptions.")
 	goimportsPrefix      = flag.String("goimports-prefix", "", "set '--local' flag for the goimports invocation. Can be overridden by InitializationOptions.")
 	enhanceSignatureHelp = flag.Bool("enhance-signature-help", false, "enhance signature help with return result. Can be overridden by InitializationOptions.")
 	buildTags            = flag.String("build-tags", "", "build tags, separated by spaces.")
+	buildConfigurations  = flag.String("build-configurations", "", "additional GOOS/GOARCH/build tags combinations to report diagnostics for, separated by commas, eg. windows/amd64,linux/arm64+integration. Can be overridden by InitializationOptions.")
+	reverseDepDepth      = flag.Int("reverse-dependency-depth", 1, "how many levels of importers of an edited package get their diagnostics refreshed, 0 disables it. Can be overridden by InitializationOptions.")
+	reverseDepBudget     = flag.Duration("reverse-dependency-budget", 5*time.Second, "total time spent on refreshing the diagnostics of importers after an edit. Can be overridden by InitializationOptions.")
+	deprecatedSeverity   = flag.String("deprecated-severity", "hint", "severity of the diagnostics on uses of deprecated symbols: none, hint, info, warning, error. Can be overridden by InitializationOptions.")
+	analyses             = flag.String("analyses", "", "names of the analyzers of the vet suite run on the packages, separated by commas, eg. printf,copylocks, or all. Defaults to none. Can be overridden by InitializationOptions.")
+	goBinary             = flag.String("go-binary", "", "path of the go command run for the workspace. Defaults to the go command of the PATH. Can be overridden by InitializationOptions.")
+	syntheticDir         = flag.String("synthetic-dir", "", "directory resolving the imports of the untitled documents, relative to the outermost workspace folder. Defaults to the outermost workspace folder. Can be overridden by InitializationOptions.")
 
 	// Compatible with sourcegraph/go-langserver, ensuring that ide-go can run, but no actual effect
 	// https://github.com/saibing/bingo/issues/163
@@ -54,6 +72,14 @@ var (
 const version = "v2-dev"
 
 func main() {
+	if langserver.IsPackagesDriver() {
+		if err := langserver.RunPackagesDriver(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.Parse()
 	log.SetFlags(0)
 
@@ -71,15 +97,57 @@ func main() {
 	cfg := langserver.NewDefaultConfig()
 	cfg.DisableFuncSnippet = *disableFuncSnippet
 	cfg.DiagnosticsStyle = *diagnosticsStyle
+	cfg.DiagnosticsDelay = *diagnosticsDelay
 	cfg.GlobalCacheStyle = *globalCacheStyle
+	cfg.IndexDirectory = *indexDir
+	cfg.MaxDepth = *maxDepth
+	cfg.UseIgnoreFiles = *useIgnoreFiles
+	cfg.DependencyMode = *dependencyMode
+	cfg.MemoryLimit = *memoryLimit
+	cfg.WatchMode = *watchMode
+	cfg.PollInterval = *pollInterval
 	cfg.FormatStyle = *formatStyle
 	cfg.GoimportsLocalPrefix = *goimportsPrefix
 	cfg.EnhanceSignatureHelp = *enhanceSignatureHelp
+	cfg.ReverseDependencyDepth = *reverseDepDepth
+	cfg.ReverseDependencyBudget = *reverseDepBudget
+	cfg.DeprecatedSeverity = *deprecatedSeverity
+	cfg.GoBinary = *goBinary
+	cfg.SyntheticDirectory = *syntheticDir
+
+	if *includePatterns != "" {
+		cfg.IncludePatterns = strings.Split(*includePatterns, ",")
+	}
+
+	if *excludePatterns != "" {
+		cfg.ExcludePatterns = strings.Split(*excludePatterns, ",")
+	}
+
+	if *analyses != "" {
+		cfg.Analyses = strings.Split(*analyses, ",")
+	}
 
 	if *buildTags != "" {
 		cfg.BuildTags = strings.Split(*buildTags, " ")
 	}
 
+	if *buildConfigurations != "" {
+		configurations, err := langserver.ParseBuildConfigurations(*buildConfigurations)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		cfg.BuildConfigurations = configurations
+	}
+
+	if flag.Arg(0) == "check" {
+		if err := runCheck(cfg, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(cfg); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -142,6 +210,43 @@ func run(cfg langserver.Config) error {
 	}
 }
 
+// runCheck implements "bingo check [-format text|json|sarif] [packages]". It
+// prints the diagnostics the editor would show for the packages, and fails
+// if any of them is an error.
+func runCheck(cfg langserver.Config, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *logfile != "" {
+		f, err := os.Create(*logfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	} else {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	summary, err := langserver.Check(context.Background(), cfg, dir, fs.Args(), *format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if summary.Errors > 0 {
+		return fmt.Errorf("%d errors, %d warnings in %d packages", summary.Errors, summary.Warnings, summary.Packages)
+	}
+	return nil
+}
+
 type stdrwc struct{}
 
 func (stdrwc) Read(p []byte) (int, error) {